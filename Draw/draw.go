@@ -9,6 +9,9 @@ import (
 	"image"
 	"image/color"
 	"image/png"
+	"math"
+	"strings"
+	"unicode"
 )
 
 var (
@@ -108,6 +111,25 @@ func (cvs *Canvas) DrawTextCenter(str string, size float64, rgba color.Color, wi
 	cvs.ctx.Fill()
 }
 
+// MeasureText 测量文字宽度
+func (cvs *Canvas) MeasureText(str string, size float64) float64 {
+	face := truetype.NewFace(rFont, &truetype.Options{Size: size})
+	cvs.ctx.SetFontFace(face)
+	w, _ := cvs.ctx.MeasureString(str)
+	return w
+}
+
+// DrawTextRight 画文字右对齐
+//
+// right: 文字右边缘的横坐标
+func (cvs *Canvas) DrawTextRight(str string, size float64, rgba color.Color, right, top int) {
+	face := truetype.NewFace(rFont, &truetype.Options{Size: size})
+	cvs.ctx.SetFontFace(face)
+	cvs.ctx.SetColor(rgba)
+	cvs.ctx.DrawStringAnchored(str, float64(right), float64(top), 1, 1)
+	cvs.ctx.Fill()
+}
+
 // DrawTextVertical 画文字, 从上到下
 //
 // str: 文字
@@ -167,3 +189,146 @@ func (cvs *Canvas) SaveBytes() ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+// 不能出现在行首的标点,换行时随前一个字留在上一行
+const lineStartForbidden = "，。、；：？！）》」』】〕”’…—,.;:?!)]}%"
+
+// 不能出现在行尾的标点,换行时随后一个字移到下一行
+const lineEndForbidden = "（《「『【〔“‘([{"
+
+// WrapText 按宽度将文字分行
+//
+// 中日韩文字可在任意字间换行,连续的英文与数字作为一个整体换行,
+// 并按避头尾规则处理标点。原文中的换行符保留
+func (cvs *Canvas) WrapText(str string, size float64, width float64) []string {
+	face := truetype.NewFace(rFont, &truetype.Options{Size: size})
+	cvs.ctx.SetFontFace(face)
+	var ret []string
+	for _, paragraph := range strings.Split(str, "\n") {
+		ret = append(ret, cvs.wrapParagraph(splitWrapUnits(paragraph), width)...)
+	}
+	return ret
+}
+
+// splitWrapUnits 拆分为不可再分的换行单位,连续的半角字母数字为一个单位
+func splitWrapUnits(str string) []string {
+	var ret []string
+	word := ""
+	for _, r := range str {
+		if r < 128 && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			word += string(r)
+			continue
+		}
+		if word != "" {
+			ret = append(ret, word)
+			word = ""
+		}
+		ret = append(ret, string(r))
+	}
+	if word != "" {
+		ret = append(ret, word)
+	}
+	return ret
+}
+
+func (cvs *Canvas) wrapParagraph(units []string, width float64) []string {
+	var ret []string
+	line := ""
+	for _, unit := range units {
+		if w, _ := cvs.ctx.MeasureString(line + unit); w <= width || line == "" {
+			line += unit
+			continue
+		}
+		// 行首禁用的标点挤入当前行
+		if strings.Contains(lineStartForbidden, unit) {
+			line += unit
+			continue
+		}
+		// 行尾为禁用的标点时,将其移到下一行
+		next := ""
+		if r := []rune(line); len(r) > 1 && strings.ContainsRune(lineEndForbidden, r[len(r)-1]) {
+			line, next = string(r[:len(r)-1]), string(r[len(r)-1])
+		}
+		if unit != " " {
+			next += unit
+		}
+		ret = append(ret, strings.TrimRight(line, " "))
+		line = next
+	}
+	if line != "" || len(ret) == 0 {
+		ret = append(ret, strings.TrimRight(line, " "))
+	}
+	return ret
+}
+
+// DrawCircle 画圆形边框
+//
+// x, y: 圆心
+// r: 半径
+func (cvs *Canvas) DrawCircle(x, y, r, lineWidth float64, rgba color.Color) {
+	cvs.ctx.SetColor(rgba)
+	cvs.ctx.SetLineWidth(lineWidth)
+	cvs.ctx.DrawCircle(x, y, r)
+	cvs.ctx.Stroke()
+}
+
+// DrawArrow 画箭头
+//
+// x, y: 箭头中心
+// length: 箭头长度
+// angle: 箭头指向的角度,0为正上方,顺时针增加
+func (cvs *Canvas) DrawArrow(x, y, length, angle float64, rgba color.Color) {
+	rad := angle * math.Pi / 180
+	sin, cos := math.Sin(rad), math.Cos(rad)
+	// 箭头朝上时的轮廓:尖端、左翼、尾部凹口、右翼
+	points := [][2]float64{
+		{0, -length / 2},
+		{-length / 3, length / 2},
+		{0, length / 4},
+		{length / 3, length / 2},
+	}
+	cvs.ctx.SetColor(rgba)
+	cvs.ctx.NewSubPath()
+	for _, p := range points {
+		cvs.ctx.LineTo(x+p[0]*cos-p[1]*sin, y+p[0]*sin+p[1]*cos)
+	}
+	cvs.ctx.ClosePath()
+	cvs.ctx.Fill()
+}
+
+// FillCircle 画实心圆
+func (cvs *Canvas) FillCircle(x, y, r float64, rgba color.Color) {
+	cvs.ctx.SetColor(rgba)
+	cvs.ctx.DrawCircle(x, y, r)
+	cvs.ctx.Fill()
+}
+
+// DrawPolyline 画折线
+//
+// points: 依次连接的点
+// dash: 虚线的线段与间隔长度,为空时画实线
+func (cvs *Canvas) DrawPolyline(points [][2]float64, lineWidth float64, dash []float64, rgba color.Color) {
+	if len(points) < 2 {
+		return
+	}
+	cvs.ctx.SetColor(rgba)
+	cvs.ctx.SetLineWidth(lineWidth)
+	cvs.ctx.SetDash(dash...)
+	cvs.ctx.NewSubPath()
+	for _, p := range points {
+		cvs.ctx.LineTo(p[0], p[1])
+	}
+	cvs.ctx.Stroke()
+	cvs.ctx.SetDash()
+}
+
+// ClipRect 之后的绘制只在矩形范围内生效,直到调用 ResetClip
+func (cvs *Canvas) ClipRect(left, top, w, h float64) {
+	cvs.ctx.DrawRectangle(left, top, w, h)
+	cvs.ctx.Clip()
+}
+
+// ResetClip 取消绘制范围限制
+func (cvs *Canvas) ResetClip() {
+	cvs.ctx.ResetClip()
+}
@@ -0,0 +1,297 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Astronomy 天文数据
+type Astronomy struct {
+	Sunrise       time.Time // 日出时间
+	Sunset        time.Time // 日落时间
+	SunAlwaysUp   bool      // 极昼,当天太阳不落
+	SunAlwaysDown bool      // 极夜,当天太阳不升
+	MoonPhase     MoonPhase // 月相
+}
+
+// MoonPhase 月相
+type MoonPhase struct {
+	Value        float64 // 月相数值,0为新月,0.5为满月
+	Name         string  // 月相名称
+	Illumination float64 // 月亮照明度,百分比数值
+	Icon         string  // 月相图标代码
+}
+
+// IsNight 判断给定时间是否处于夜间
+func (a Astronomy) IsNight(t time.Time) bool {
+	if a.SunAlwaysUp {
+		return false
+	}
+	if a.SunAlwaysDown {
+		return true
+	}
+	if a.Sunrise.IsZero() || a.Sunset.IsZero() {
+		return false
+	}
+	return t.Before(a.Sunrise) || !t.Before(a.Sunset)
+}
+
+const (
+	// 朔望月长度,单位:天
+	synodicMonth = 29.530588853
+	// 太阳天顶角,包含大气折射与太阳视半径
+	sunZenith = 90.833
+)
+
+// 2000-01-06 18:14 UTC 的新月,作为月相推算基准
+var knownNewMoon = time.Date(2000, 1, 6, 18, 14, 0, 0, time.UTC)
+
+// 月相名称与图标,按新月到残月排列
+var moonPhaseNames = []struct {
+	Name string
+	Icon string
+}{
+	{"新月", "800"},
+	{"蛾眉月", "801"},
+	{"上弦月", "802"},
+	{"盈凸月", "803"},
+	{"满月", "804"},
+	{"亏凸月", "805"},
+	{"下弦月", "806"},
+	{"残月", "807"},
+}
+
+// 白天天气图标对应的夜间图标
+var nightIcons = map[string]string{
+	"100": "150", // 晴
+	"101": "151", // 多云
+	"102": "152", // 少云
+	"103": "153", // 晴间多云
+	"300": "350", // 阵雨
+	"301": "351", // 强阵雨
+	"406": "456", // 阵雨夹雪
+	"407": "457", // 阵雪
+}
+
+// NightIcon 获取天气图标的夜间版本,没有夜间版本则原样返回
+func NightIcon(icon string) string {
+	if v, ifSet := nightIcons[icon]; ifSet {
+		return v
+	}
+	return icon
+}
+
+// DayIcon 获取天气图标的白天版本,没有白天版本则原样返回
+func DayIcon(icon string) string {
+	for day, night := range nightIcons {
+		if night == icon {
+			return day
+		}
+	}
+	return icon
+}
+
+// CalcMoonPhase 离线计算月相
+func CalcMoonPhase(t time.Time) MoonPhase {
+	days := t.UTC().Sub(knownNewMoon).Hours() / 24
+	age := math.Mod(days, synodicMonth)
+	if age < 0 {
+		age += synodicMonth
+	}
+	value := age / synodicMonth
+	index := int(math.Floor(value*8+0.5)) % 8
+	return MoonPhase{
+		Value:        value,
+		Name:         moonPhaseNames[index].Name,
+		Illumination: (1 - math.Cos(2*math.Pi*value)) / 2 * 100,
+		Icon:         moonPhaseNames[index].Icon,
+	}
+}
+
+// CalcSun 离线计算日出日落
+//
+// lat: 纬度
+// lon: 经度
+// date: 日期,结果使用该日期所在时区
+func CalcSun(lat, lon float64, date time.Time) (ret Astronomy) {
+	sunrise, riseState := calcSunTime(lat, lon, date, true)
+	sunset, setState := calcSunTime(lat, lon, date, false)
+	if riseState > 0 || setState > 0 {
+		ret.SunAlwaysDown = true
+		return ret
+	}
+	if riseState < 0 || setState < 0 {
+		ret.SunAlwaysUp = true
+		return ret
+	}
+	ret.Sunrise = sunrise
+	ret.Sunset = sunset
+	return ret
+}
+
+// calcSunTime 计算日出或日落时间
+//
+// state: 0为正常, 1为太阳全天不升, -1为太阳全天不落
+func calcSunTime(lat, lon float64, date time.Time, rising bool) (ret time.Time, state int) {
+	rad := math.Pi / 180
+	lngHour := lon / 15
+	t := float64(date.YearDay())
+	if rising {
+		t += (6 - lngHour) / 24
+	} else {
+		t += (18 - lngHour) / 24
+	}
+	// 太阳平近点角
+	m := 0.9856*t - 3.289
+	// 太阳真黄经
+	l := normalizeRange(m+1.916*math.Sin(m*rad)+0.020*math.Sin(2*m*rad)+282.634, 360)
+	// 太阳赤经
+	ra := normalizeRange(math.Atan(0.91764*math.Tan(l*rad))/rad, 360)
+	ra += math.Floor(l/90)*90 - math.Floor(ra/90)*90
+	ra /= 15
+	// 太阳赤纬
+	sinDec := 0.39782 * math.Sin(l*rad)
+	cosDec := math.Cos(math.Asin(sinDec))
+	// 太阳时角
+	cosH := (math.Cos(sunZenith*rad) - sinDec*math.Sin(lat*rad)) / (cosDec * math.Cos(lat*rad))
+	if cosH > 1 {
+		return ret, 1
+	}
+	if cosH < -1 {
+		return ret, -1
+	}
+	h := math.Acos(cosH) / rad
+	if rising {
+		h = 360 - h
+	}
+	h /= 15
+	ut := normalizeRange(h+ra-0.06571*t-6.622-lngHour, 24)
+	y, mon, d := date.Date()
+	ret = time.Date(y, mon, d, 0, 0, 0, 0, time.UTC).
+		Add(time.Duration(ut * float64(time.Hour))).
+		In(date.Location())
+	// 时区偏移可能导致结果落在前一天或后一天
+	if ret.Day() != d {
+		if ret.Before(date) {
+			ret = ret.Add(24 * time.Hour)
+		} else {
+			ret = ret.Add(-24 * time.Hour)
+		}
+	}
+	return ret, 0
+}
+
+func normalizeRange(v, max float64) float64 {
+	v = math.Mod(v, max)
+	if v < 0 {
+		v += max
+	}
+	return v
+}
+
+// GetAstronomyLocal 根据城市坐标离线计算天文数据
+func GetAstronomyLocal(cityID string, date time.Time) (Astronomy, error) {
+	loc, err := GetLocation(cityID)
+	if err != nil {
+		return Astronomy{}, err
+	}
+	lat, err := strconv.ParseFloat(loc.Latitude, 64)
+	if err != nil {
+		return Astronomy{}, err
+	}
+	lon, err := strconv.ParseFloat(loc.Longitude, 64)
+	if err != nil {
+		return Astronomy{}, err
+	}
+	ret := CalcSun(lat, lon, date)
+	ret.MoonPhase = CalcMoonPhase(date)
+	return ret, nil
+}
+
+type astronomySunRaw struct {
+	Code       string `json:"code"`
+	UpdateTime string `json:"updateTime"`
+	FxLink     string `json:"fxLink"`
+	Sunrise    string `json:"sunrise"`
+	Sunset     string `json:"sunset"`
+}
+
+type astronomyMoonRaw struct {
+	Code       string `json:"code"`
+	UpdateTime string `json:"updateTime"`
+	FxLink     string `json:"fxLink"`
+	Moonrise   string `json:"moonrise"`
+	Moonset    string `json:"moonset"`
+	MoonPhase  []struct {
+		FxTime       string `json:"fxTime"`
+		Value        string `json:"value"`
+		Name         string `json:"name"`
+		Illumination string `json:"illumination"`
+		Icon         string `json:"icon"`
+	} `json:"moonPhase"`
+}
+
+// 和风天气接口的时间格式
+const qweatherTimeLayout = "2006-01-02T15:04-07:00"
+
+// GetAstronomy 通过和风天气接口获取天文数据
+func GetAstronomy(cityID, host, key string, date time.Time, opt RequestOptions) (ret Astronomy, err error) {
+	if len(key) == 0 && opt.Signer == nil {
+		return ret, errors.New("天文接口需要秘钥")
+	}
+	dateStr := date.Format("20060102")
+	sunRaw := astronomySunRaw{}
+	err = getQWeatherJson(fmt.Sprintf("%s/astronomy/sun?location=%s&date=%s%s", host, url.QueryEscape(cityID), dateStr, opt.query(key)), opt, &sunRaw, &sunRaw.Code)
+	if err != nil {
+		return ret, err
+	}
+	moonRaw := astronomyMoonRaw{}
+	err = getQWeatherJson(fmt.Sprintf("%s/astronomy/moon?location=%s&date=%s%s", host, url.QueryEscape(cityID), dateStr, opt.query(key)), opt, &moonRaw, &moonRaw.Code)
+	if err != nil {
+		return ret, err
+	}
+	// 日出日落为空表示当天极昼或极夜,交给离线计算判断
+	if sunRaw.Sunrise == "" || sunRaw.Sunset == "" {
+		if local, err := GetAstronomyLocal(cityID, date); err == nil {
+			ret.SunAlwaysUp = local.SunAlwaysUp
+			ret.SunAlwaysDown = local.SunAlwaysDown
+		}
+	} else {
+		if ret.Sunrise, err = time.Parse(qweatherTimeLayout, sunRaw.Sunrise); err != nil {
+			return ret, err
+		}
+		if ret.Sunset, err = time.Parse(qweatherTimeLayout, sunRaw.Sunset); err != nil {
+			return ret, err
+		}
+	}
+	ret.MoonPhase = CalcMoonPhase(date)
+	// 取离当前时间最近的一条月相数据
+	for _, v := range moonRaw.MoonPhase {
+		fxTime, err := time.Parse(qweatherTimeLayout, v.FxTime)
+		if err != nil || fxTime.After(date) {
+			break
+		}
+		ret.MoonPhase.Name = v.Name
+		ret.MoonPhase.Icon = v.Icon
+		ret.MoonPhase.Value, _ = strconv.ParseFloat(v.Value, 64)
+		ret.MoonPhase.Illumination, _ = strconv.ParseFloat(v.Illumination, 64)
+	}
+	return ret, nil
+}
+
+// getQWeatherJson 请求和风天气接口并解析,code指向解析结果中的返回码
+func getQWeatherJson(url string, opt RequestOptions, v any, code *string) error {
+	respData, status, err := qweatherGet(url, opt)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(respData, v); err != nil {
+		return newDecodeError(ProviderQWeather, url, status, err)
+	}
+	return checkRespCode(url, status, *code)
+}
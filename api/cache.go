@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CacheProvider 缓存数据源
+//
+// 获取成功时将数据写入磁盘,获取失败时使用最后一次成功的数据
+type CacheProvider struct {
+	Provider WeatherProvider
+	Path     string           // 缓存目录
+	Timeout  time.Duration    // 获取天气的截止时间,超时后使用缓存数据,为0时不限制
+	Log      func(msg string) // 日志输出,可为空
+}
+
+// NewCacheProvider 创建缓存数据源
+func NewCacheProvider(provider WeatherProvider, path string) *CacheProvider {
+	return &CacheProvider{
+		Provider: provider,
+		Path:     path,
+	}
+}
+
+func (p *CacheProvider) Name() string {
+	return p.Provider.Name()
+}
+
+func (p *CacheProvider) log(format string, args ...any) {
+	if p.Log != nil {
+		p.Log(fmt.Sprintf(format, args...))
+	}
+}
+
+func (p *CacheProvider) cacheFile(cityID string) string {
+	return filepath.Join(p.Path, cityFileName("weather_cache_", cityID, ".json"))
+}
+
+// cityFileName 按城市ID生成缓存、历史与记录文件的文件名
+//
+// 城市ID可能是任意文字(例如 OpenWeather 的城市名称),只由字母、数字、
+// '-'、'_'、','与不连续的'.'组成时原样使用,兼容已有文件;
+// 否则将其余字符与'.'替换为'_'并附加原ID的哈希,避免路径穿越与不同ID重名
+func cityFileName(prefix, cityID, ext string) string {
+	safe := cityID != "" && len(cityID) <= 64 && !strings.Contains(cityID, "..")
+	name := []byte(cityID)
+	for i, c := range name {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == ',' || c == '.' {
+			continue
+		}
+		name[i] = '_'
+		safe = false
+	}
+	if safe {
+		return prefix + cityID + ext
+	}
+	name = bytes.ReplaceAll(name, []byte("."), []byte("_"))
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	sum := sha1.Sum([]byte(cityID))
+	return prefix + string(name) + "_" + hex.EncodeToString(sum[:8]) + ext
+}
+
+func (p *CacheProvider) GetWeather(ctx context.Context, cityID string) (ret Weather, err error) {
+	// 截止时间放在缓存层内,外层截止前即可改用缓存,超时后停止未完成的请求
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	errs := FetchAll(ctx, FetchTask{Name: SectionNow, Fetch: func() (func(), error) {
+		w, err := p.Provider.GetWeather(ctx, cityID)
+		return func() { ret = w }, err
+	}})
+	err = errs[SectionNow]
+	if err == nil {
+		if err := p.save(cityID, ret); err != nil {
+			p.log("天气缓存写入失败:%v", err)
+		}
+		return ret, nil
+	}
+	cache, cacheErr := p.Load(cityID)
+	if cacheErr != nil {
+		return ret, err
+	}
+	p.log("天气获取失败,使用%s的缓存数据:%v", cache.UpdateTime, err)
+	cache.Stale = true
+	return cache, nil
+}
+
+// Unwrap 被缓存的数据源,天文、历史天气等能力通过 Capability 查找
+func (p *CacheProvider) Unwrap() WeatherProvider {
+	return p.Provider
+}
+
+// Load 读取城市的缓存数据
+func (p *CacheProvider) Load(cityID string) (ret Weather, err error) {
+	data, err := os.ReadFile(p.cacheFile(cityID))
+	if err != nil {
+		return ret, err
+	}
+	if err = json.Unmarshal(data, &ret); err != nil {
+		return ret, err
+	}
+	ret.parse()
+	return ret, nil
+}
+
+func (p *CacheProvider) save(cityID string, weather Weather) error {
+	data, err := json.Marshal(weather)
+	if err != nil {
+		return err
+	}
+	// 先写临时文件再替换,避免写入中断留下损坏的缓存
+	tmp := p.cacheFile(cityID) + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.cacheFile(cityID))
+}
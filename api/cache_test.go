@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowProvider 在指定时间后才返回的数据源
+type slowProvider struct {
+	delay time.Duration
+}
+
+func (p slowProvider) Name() string {
+	return "slow"
+}
+
+func (p slowProvider) GetWeather(ctx context.Context, cityID string) (Weather, error) {
+	select {
+	case <-time.After(p.delay):
+		return Weather{Provider: p.Name(), UpdateTime: "2026-10-19 13:00:00"}, nil
+	case <-ctx.Done():
+		return Weather{}, ctx.Err()
+	}
+}
+
+func TestCacheProviderTimeout(t *testing.T) {
+	cache := NewCacheProvider(slowProvider{delay: time.Second}, t.TempDir())
+	cache.Timeout = 20 * time.Millisecond
+	if err := cache.save("101010100", Weather{Provider: "slow", UpdateTime: "2026-10-19 12:00:00"}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	w, err := cache.GetWeather(context.Background(), "101010100")
+	if err != nil {
+		t.Fatalf("GetWeather() error = %v", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("GetWeather() took %v, want about %v", d, cache.Timeout)
+	}
+	if !w.Stale || w.UpdateTime != "2026-10-19 12:00:00" {
+		t.Errorf("GetWeather() = %s stale=%v, want cached data", w.UpdateTime, w.Stale)
+	}
+}
+
+func TestCityFileName(t *testing.T) {
+	// 只含安全字符的ID原样使用,兼容已有文件
+	for _, id := range []string{"101010100", "Beijing,CN", "116.41,39.92"} {
+		if got, want := cityFileName("history_", id, ".json"), "history_"+id+".json"; got != want {
+			t.Errorf("cityFileName(%q) = %q, want %q", id, got, want)
+		}
+	}
+	seen := make(map[string]string)
+	for _, id := range []string{"../../etc/passwd", "a/b", `a\b`, "New York", "New_York ", "北京", "..", strings.Repeat("x", 100)} {
+		got := cityFileName("history_", id, ".json")
+		if strings.ContainsAny(got, `/\ `) || strings.Contains(got, "..") || filepath.Base(got) != got {
+			t.Errorf("cityFileName(%q) = %q, not a plain file name", id, got)
+		}
+		if !strings.HasPrefix(got, "history_") || !strings.HasSuffix(got, ".json") {
+			t.Errorf("cityFileName(%q) = %q, lost prefix or extension", id, got)
+		}
+		if other, ifSet := seen[got]; ifSet {
+			t.Errorf("cityFileName(%q) and cityFileName(%q) both = %q", id, other, got)
+		}
+		seen[got] = id
+	}
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// 接口错误类型,可通过 errors.Is 判断 APIError 属于哪一类
+var (
+	ErrNoData            = errors.New("城市数据不存在")        // 请求成功,但查询的地区暂无数据
+	ErrBadRequest        = errors.New("请求错误")           // 请求参数错误
+	ErrUnauthorized      = errors.New("认证失败,请联系管理员")    // 秘钥或令牌无效
+	ErrPaymentRequired   = errors.New("超过访问次数,请联系管理员")  // 超过访问次数或余额不足
+	ErrForbidden         = errors.New("无访问权限,请联系管理员")   // 无权访问该接口
+	ErrNotFound          = errors.New("数据或地区不存在")       // 查询的数据或地区不存在
+	ErrTooManyRequests   = errors.New("超过限制访问次数,请稍后再试") // 超过请求频率限制
+	ErrServer            = errors.New("服务器内部错误,请联系管理员") // 接口服务器异常
+	ErrMalformedResponse = errors.New("接口返回数据格式错误")     // 响应无法解析或缺少必要字段
+	ErrUnknown           = errors.New("未知错误")
+)
+
+// 错误码对应的错误类型,和风天气与 OpenWeatherMap 的错误码与HTTP状态码一致
+var codeErrors = map[int]error{
+	204: ErrNoData,
+	400: ErrBadRequest,
+	401: ErrUnauthorized,
+	402: ErrPaymentRequired,
+	403: ErrForbidden,
+	404: ErrNotFound,
+	429: ErrTooManyRequests,
+}
+
+// APIError 接口返回的错误
+type APIError struct {
+	Provider string // 数据源名称
+	Endpoint string // 接口路径
+	Status   int    // HTTP状态码
+	Code     string // 接口返回的错误码,可能为空
+	Message  string // 接口返回的错误信息,可能为空
+	Err      error  // 错误类型,为上方的 Err 变量之一
+}
+
+func (e *APIError) Error() string {
+	msg := e.Err.Error()
+	if e.Err == ErrUnknown {
+		code := e.Code
+		if code == "" {
+			code = strconv.Itoa(e.Status)
+		}
+		msg = fmt.Sprintf("未知错误,错误码:%s", code)
+	}
+	if e.Message != "" {
+		msg += ":" + e.Message
+	}
+	return msg
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary 是否为稍后重试可能成功的错误
+func (e *APIError) Temporary() bool {
+	return e.Err == ErrTooManyRequests || e.Err == ErrServer
+}
+
+// NetworkError 请求未能得到响应,例如连接失败或超时
+type NetworkError struct {
+	Endpoint string // 接口路径
+	Err      error
+}
+
+func (e *NetworkError) Error() string {
+	// 不输出完整地址,避免秘钥出现在日志与屏幕上
+	err := e.Err
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	return fmt.Sprintf("网络错误:%v", err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout 是否为超时错误
+func (e *NetworkError) Timeout() bool {
+	var netErr net.Error
+	return errors.As(e.Err, &netErr) && netErr.Timeout()
+}
+
+// IsRetryable 判断错误是否值得重试或切换数据源后再试
+//
+// 网络错误、超时、限流与服务器错误可以重试,认证、次数用尽与数据不存在等错误重试无意义
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrFetchTimeout) {
+		return true
+	}
+	// 次数用完前不会恢复,不必重试
+	if errors.Is(err, ErrQuotaExceeded) {
+		return false
+	}
+	var netErr *NetworkError
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	return false
+}
+
+// newAPIError 根据错误码创建接口错误,错误码表示成功时返回nil
+//
+// code为空时使用HTTP状态码判断
+func newAPIError(provider, rawURL string, status int, code string) error {
+	n := status
+	if code != "" {
+		var err error
+		if n, err = strconv.Atoi(code); err != nil {
+			n = -1
+		}
+	}
+	if n == 200 {
+		return nil
+	}
+	ret := &APIError{
+		Provider: provider,
+		Endpoint: endpointOf(rawURL),
+		Status:   status,
+		Code:     code,
+		Err:      ErrUnknown,
+	}
+	if err, ifSet := codeErrors[n]; ifSet {
+		ret.Err = err
+	} else if n >= 500 && n < 600 {
+		ret.Err = ErrServer
+	}
+	return ret
+}
+
+// newDecodeError 响应解析失败,HTTP状态码异常时优先按状态码返回错误
+func newDecodeError(provider, rawURL string, status int, err error) error {
+	if status != 0 && status != http.StatusOK {
+		return newAPIError(provider, rawURL, status, "")
+	}
+	return &APIError{
+		Provider: provider,
+		Endpoint: endpointOf(rawURL),
+		Status:   status,
+		Message:  err.Error(),
+		Err:      ErrMalformedResponse,
+	}
+}
+
+// endpointOf 取出接口路径,不包含参数
+func endpointOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
+
+// doRequest 发送请求并读取响应,网络错误包装为 NetworkError
+func doRequest(req *http.Request) (data []byte, status int, err error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// 次数保护拦截的请求并未发出,不作为网络错误
+		if errors.Is(err, ErrQuotaExceeded) {
+			return nil, 0, ErrQuotaExceeded
+		}
+		return nil, 0, &NetworkError{Endpoint: req.URL.Path, Err: err}
+	}
+	defer resp.Body.Close()
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, &NetworkError{Endpoint: req.URL.Path, Err: err}
+	}
+	return data, resp.StatusCode, nil
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCheckRespCode(t *testing.T) {
+	const rawURL = "https://devapi.qweather.com/v7/weather/now?location=101010100&key=k"
+	tests := []struct {
+		status int
+		code   string
+		want   error
+	}{
+		{http.StatusOK, "200", nil},
+		{http.StatusOK, "", ErrMalformedResponse},
+		{0, "", ErrMalformedResponse},
+		{http.StatusOK, "204", ErrNoData},
+		{http.StatusUnauthorized, "", ErrUnauthorized},
+		{http.StatusOK, "abc", ErrUnknown},
+	}
+	for _, tt := range tests {
+		err := checkRespCode(rawURL, tt.status, tt.code)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("checkRespCode(%d, %q) = %v, want nil", tt.status, tt.code, err)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("checkRespCode(%d, %q) = %v, want %v", tt.status, tt.code, err, tt.want)
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Endpoint != "/v7/weather/now" {
+			t.Errorf("checkRespCode(%d, %q) Endpoint = %q", tt.status, tt.code, apiErr.Endpoint)
+		}
+	}
+}
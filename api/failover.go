@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProviderFailover 自动切换数据源
+const ProviderFailover = "failover"
+
+// ProviderHealth 数据源健康状态
+type ProviderHealth struct {
+	Name        string    // 数据源名称
+	Failures    int       // 连续失败次数
+	LastError   string    // 最后一次错误
+	LastFailure time.Time // 最后一次失败时间
+	LastSuccess time.Time // 最后一次成功时间
+}
+
+var (
+	providerHealthLock sync.Mutex
+	providerHealth     = make(map[string]*ProviderHealth)
+)
+
+// GetProviderHealth 获取数据源健康状态
+func GetProviderHealth(name string) ProviderHealth {
+	providerHealthLock.Lock()
+	defer providerHealthLock.Unlock()
+	if h, ifSet := providerHealth[name]; ifSet {
+		return *h
+	}
+	return ProviderHealth{Name: name}
+}
+
+func recordProviderResult(name string, err error) {
+	providerHealthLock.Lock()
+	defer providerHealthLock.Unlock()
+	h, ifSet := providerHealth[name]
+	if !ifSet {
+		h = &ProviderHealth{Name: name}
+		providerHealth[name] = h
+	}
+	if err == nil {
+		h.Failures = 0
+		h.LastError = ""
+		h.LastSuccess = time.Now()
+		return
+	}
+	h.Failures++
+	h.LastError = err.Error()
+	h.LastFailure = time.Now()
+}
+
+// isAccountError 判断是否为账号问题导致的错误,短期内重试不会成功
+func isAccountError(err error) bool {
+	return errors.Is(err, ErrUnauthorized) ||
+		errors.Is(err, ErrPaymentRequired) ||
+		errors.Is(err, ErrForbidden) ||
+		errors.Is(err, ErrQuotaExceeded)
+}
+
+// markProviderUnhealthy 将连续失败次数至少提升到 failures,使数据源立即进入冷却
+func markProviderUnhealthy(name string, failures int) {
+	providerHealthLock.Lock()
+	defer providerHealthLock.Unlock()
+	if h, ifSet := providerHealth[name]; ifSet && h.Failures < failures {
+		h.Failures = failures
+	}
+}
+
+// FailoverProvider 按顺序尝试多个数据源,出错或超时自动切换到下一个
+type FailoverProvider struct {
+	Providers   []WeatherProvider
+	Timeout     time.Duration    // 单个数据源的超时时间上限,有共享截止时间时按剩余时间分配
+	MaxFailures int              // 连续失败达到该次数后暂时跳过该数据源
+	Cooldown    time.Duration    // 跳过数据源的时长
+	Log         func(msg string) // 日志输出,可为空
+}
+
+// NewFailoverProvider 创建自动切换数据源
+func NewFailoverProvider(providers ...WeatherProvider) *FailoverProvider {
+	return &FailoverProvider{
+		Providers:   providers,
+		Timeout:     20 * time.Second,
+		MaxFailures: 3,
+		Cooldown:    30 * time.Minute,
+	}
+}
+
+func (p *FailoverProvider) Name() string {
+	names := make([]string, 0, len(p.Providers))
+	for _, v := range p.Providers {
+		names = append(names, v.Name())
+	}
+	return ProviderFailover + "(" + strings.Join(names, ",") + ")"
+}
+
+func (p *FailoverProvider) log(format string, args ...any) {
+	if p.Log != nil {
+		p.Log(fmt.Sprintf(format, args...))
+	}
+}
+
+// healthy 判断数据源是否可用,连续失败过多且仍在冷却期内视为不可用
+func (p *FailoverProvider) healthy(name string) bool {
+	h := GetProviderHealth(name)
+	if p.MaxFailures <= 0 || h.Failures < p.MaxFailures {
+		return true
+	}
+	return time.Since(h.LastFailure) >= p.Cooldown
+}
+
+// order 获取尝试顺序,不可用的数据源排在最后作为兜底
+func (p *FailoverProvider) order() []WeatherProvider {
+	ret := make([]WeatherProvider, 0, len(p.Providers))
+	var unhealthy []WeatherProvider
+	for _, v := range p.Providers {
+		if p.healthy(v.Name()) {
+			ret = append(ret, v)
+		} else {
+			unhealthy = append(unhealthy, v)
+		}
+	}
+	return append(ret, unhealthy...)
+}
+
+// budget 单个数据源可用的时间
+//
+// 有共享截止时间时,剩余时间由尚未尝试的数据源平分,前面的数据源卡住时后面的仍有时间;
+// Timeout 为单个数据源的上限
+func (p *FailoverProvider) budget(ctx context.Context, left int) time.Duration {
+	ret := p.Timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if share := time.Until(deadline) / time.Duration(left); ret <= 0 || share < ret {
+			ret = share
+		}
+	}
+	return ret
+}
+
+// getWeather 在时间内从数据源获取天气,超时后取消未完成的请求,不再占用次数与限流
+func (p *FailoverProvider) getWeather(ctx context.Context, provider WeatherProvider, cityID string, left int) (Weather, error) {
+	if ctx.Err() != nil {
+		return Weather{}, ErrFetchTimeout
+	}
+	if timeout := p.budget(ctx, left); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	type result struct {
+		weather Weather
+		err     error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		w, err := provider.GetWeather(ctx, cityID)
+		ch <- result{w, err}
+	}()
+	select {
+	case r := <-ch:
+		return r.weather, r.err
+	case <-ctx.Done():
+		return Weather{}, ErrFetchTimeout
+	}
+}
+
+func (p *FailoverProvider) GetWeather(ctx context.Context, cityID string) (ret Weather, err error) {
+	if len(p.Providers) == 0 {
+		return ret, errors.New("没有可用的数据源")
+	}
+	var errs []string
+	providers := p.order()
+	for i, provider := range providers {
+		ret, err = p.getWeather(ctx, provider, cityID, len(providers)-i)
+		recordProviderResult(provider.Name(), err)
+		if err == nil {
+			p.log("使用数据源:%s", provider.Name())
+			return ret, nil
+		}
+		p.log("数据源%s获取失败:%v", provider.Name(), err)
+		if isAccountError(err) {
+			// 认证失败或次数用尽时跳过该数据源,直到冷却结束
+			markProviderUnhealthy(provider.Name(), p.MaxFailures)
+		}
+		errs = append(errs, provider.Name()+":"+err.Error())
+	}
+	return ret, errors.New("所有数据源均获取失败\n" + strings.Join(errs, "\n"))
+}
+
+// Unwrap 全部数据源,至少一个数据源支持时 Capability 才认为支持该能力
+func (p *FailoverProvider) Unwrap() []WeatherProvider {
+	return p.Providers
+}
+
+// failoverCall 按顺序使用支持该能力的可用数据源,返回第一个成功的结果
+func failoverCall[T, R any](p *FailoverProvider, name string, call func(T) (R, error)) (ret R, err error) {
+	err = fmt.Errorf("没有支持%s的数据源", name)
+	for _, provider := range p.order() {
+		if v, ok := Capability[T](provider); ok {
+			if ret, err = call(v); err == nil {
+				return ret, nil
+			}
+		}
+	}
+	return ret, err
+}
+
+// GetAstronomy 使用第一个支持天文数据的可用数据源
+func (p *FailoverProvider) GetAstronomy(ctx context.Context, cityID string, date time.Time) (Astronomy, error) {
+	return failoverCall(p, "天文数据", func(v AstronomyProvider) (Astronomy, error) {
+		return v.GetAstronomy(ctx, cityID, date)
+	})
+}
+
+// GetHistorical 使用第一个支持历史天气的可用数据源
+func (p *FailoverProvider) GetHistorical(ctx context.Context, cityID string, date time.Time) (DaySummary, error) {
+	return failoverCall(p, "历史天气", func(v HistoricalProvider) (DaySummary, error) {
+		return v.GetHistorical(ctx, cityID, date)
+	})
+}
+
+// GetActiveStorms 使用第一个支持台风数据的可用数据源
+func (p *FailoverProvider) GetActiveStorms(ctx context.Context, basin string) ([]StormTrack, error) {
+	return failoverCall(p, "台风数据", func(v StormProvider) ([]StormTrack, error) {
+		return v.GetActiveStorms(ctx, basin)
+	})
+}
+
+// GetTide 使用第一个支持潮汐数据的可用数据源
+func (p *FailoverProvider) GetTide(ctx context.Context, poiID string, date time.Time) (TideTable, error) {
+	return failoverCall(p, "潮汐数据", func(v TideProvider) (TideTable, error) {
+		return v.GetTide(ctx, poiID, date)
+	})
+}
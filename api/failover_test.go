@@ -0,0 +1,50 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// hangingProvider 直到取消才返回的数据源,返回时记录取消的原因
+type hangingProvider struct {
+	cancelled chan error
+}
+
+func (p hangingProvider) Name() string {
+	return "hanging"
+}
+
+func (p hangingProvider) GetWeather(ctx context.Context, cityID string) (Weather, error) {
+	<-ctx.Done()
+	p.cancelled <- ctx.Err()
+	return Weather{}, ctx.Err()
+}
+
+func TestFailoverSharesDeadline(t *testing.T) {
+	hanging := hangingProvider{cancelled: make(chan error, 1)}
+	p := NewFailoverProvider(hanging, slowProvider{delay: 10 * time.Millisecond})
+	p.MaxFailures = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	w, err := p.GetWeather(ctx, "101010100")
+	if err != nil {
+		t.Fatalf("GetWeather() error = %v", err)
+	}
+	if w.Provider != "slow" {
+		t.Errorf("Provider = %q, want slow", w.Provider)
+	}
+	// 剩余时间由两个数据源平分,第一个卡住时只占用一半
+	if d := time.Since(start); d > 300*time.Millisecond {
+		t.Errorf("GetWeather() took %v, want about half of the deadline", d)
+	}
+	select {
+	case err := <-hanging.cancelled:
+		if err != context.DeadlineExceeded {
+			t.Errorf("hanging provider stopped with %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(time.Second):
+		t.Error("hanging provider was not cancelled")
+	}
+}
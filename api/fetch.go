@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrFetchTimeout 请求未在截止时间前完成
+var ErrFetchTimeout = errors.New("请求超时")
+
+// 天气数据的组成部分,同时作为 Weather.Raw 与 Weather.Missing 的键
+const (
+	SectionNow     = "now"     // 实时天气
+	SectionIndices = "indices" // 生活指数
+)
+
+// FetchTask 并发获取任务
+//
+// Fetch 在独立的协程中执行,返回的 apply 只会在截止时间前完成时由调用方协程执行,
+// 因此写入结果的操作应放在 apply 中
+type FetchTask struct {
+	Name  string
+	Fetch func() (apply func(), err error)
+}
+
+// FetchAll 并发执行所有任务,在截止时间前收集结果
+//
+// ctx: 共享的截止时间,任务应使用同一个 ctx 发出请求,截止后未完成的请求随之取消;
+// 没有截止时间时等待全部完成。
+// 返回失败或超时的任务,键为任务名称
+func FetchAll(ctx context.Context, tasks ...FetchTask) map[string]error {
+	type result struct {
+		name  string
+		apply func()
+		err   error
+	}
+	ch := make(chan result, len(tasks))
+	for _, task := range tasks {
+		go func(task FetchTask) {
+			apply, err := task.Fetch()
+			ch <- result{task.Name, apply, err}
+		}(task)
+	}
+	errs := make(map[string]error)
+	done := make(map[string]bool)
+	for len(done) < len(tasks) {
+		select {
+		case r := <-ch:
+			done[r.name] = true
+			if r.err != nil {
+				errs[r.name] = r.err
+			} else if r.apply != nil {
+				r.apply()
+			}
+		case <-ctx.Done():
+			for _, task := range tasks {
+				if !done[task.Name] {
+					errs[task.Name] = ErrFetchTimeout
+				}
+			}
+			return errs
+		}
+	}
+	return errs
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFetchAllDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var fast bool
+	stopped := make(chan error, 1)
+	errs := FetchAll(ctx,
+		FetchTask{Name: "fast", Fetch: func() (func(), error) {
+			return func() { fast = true }, nil
+		}},
+		FetchTask{Name: "slow", Fetch: func() (func(), error) {
+			<-ctx.Done()
+			stopped <- ctx.Err()
+			return func() { t.Error("apply of a timed-out task ran") }, nil
+		}},
+	)
+	if !fast || errs["fast"] != nil {
+		t.Errorf("fast task = %v/%v, want applied", fast, errs["fast"])
+	}
+	if !errors.Is(errs["slow"], ErrFetchTimeout) {
+		t.Errorf("slow task error = %v, want %v", errs["slow"], ErrFetchTimeout)
+	}
+	// 同一个 ctx 发出的请求在截止时间取消
+	select {
+	case err := <-stopped:
+		if err != context.DeadlineExceeded {
+			t.Errorf("slow task stopped with %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("slow task was not cancelled")
+	}
+}
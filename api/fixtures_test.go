@@ -0,0 +1,158 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// 以下测试逐项对应 testdata/README.md 中的预期结果
+
+func TestQWeatherErrorFixtures(t *testing.T) {
+	tests := []struct {
+		file      string
+		want      error
+		retryable bool
+	}{
+		{"qweather/code_204.json", ErrNoData, false},
+		{"qweather/code_401.json", ErrUnauthorized, false},
+		{"qweather/code_402.json", ErrPaymentRequired, false},
+		{"qweather/code_429.json", ErrTooManyRequests, true},
+		{"qweather/malformed.json", ErrMalformedResponse, false},
+		{"qweather/empty_body.json", ErrMalformedResponse, false},
+	}
+	date := time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local)
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			srv := newFixtureServer(t, map[string]string{"*": tt.file})
+			opt := RequestOptions{}
+			// 任意和风接口都应得到相同的错误
+			calls := map[string]func() error{
+				"GetCurrentWeather": func() error {
+					_, _, err := GetCurrentWeather("101010100", srv.URL, "test", opt)
+					return err
+				},
+				"GetWeatherIndex": func() error {
+					_, _, err := GetWeatherIndex("101010100", srv.URL, "test", opt)
+					return err
+				},
+				"GetAstronomy": func() error {
+					_, err := GetAstronomy("101010100", srv.URL, "test", date, opt)
+					return err
+				},
+			}
+			for name, call := range calls {
+				err := call()
+				if !errors.Is(err, tt.want) {
+					t.Errorf("%s() error = %v, want %v", name, err, tt.want)
+				}
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) || apiErr.Provider != ProviderQWeather {
+					t.Errorf("%s() error = %#v, want *APIError from %s", name, err, ProviderQWeather)
+				}
+				if got := IsRetryable(err); got != tt.retryable {
+					t.Errorf("%s() IsRetryable = %v, want %v", name, got, tt.retryable)
+				}
+			}
+		})
+	}
+}
+
+func TestQWeatherCurrentFixture(t *testing.T) {
+	srv := newFixtureServer(t, map[string]string{"/weather/now": "qweather/now_200.json"})
+	ret, raw, err := GetCurrentWeather("101010100", srv.URL, "test", RequestOptions{})
+	if err != nil {
+		t.Fatalf("GetCurrentWeather() error = %v", err)
+	}
+	if ret.Now.Temp != "18" {
+		t.Errorf("Now.Temp = %q, want 18", ret.Now.Temp)
+	}
+	if len(raw) == 0 {
+		t.Error("raw response is empty")
+	}
+}
+
+func TestQWeatherIndexFixtures(t *testing.T) {
+	srv := newFixtureServer(t, map[string]string{"/indices/1d": "qweather/indices_1d_200.json"})
+	ret, _, err := GetWeatherIndex("101010100", srv.URL, "test", RequestOptions{})
+	if err != nil {
+		t.Fatalf("GetWeatherIndex() error = %v", err)
+	}
+	if len(ret.Days) != 1 {
+		t.Fatalf("len(Days) = %d, want 1", len(ret.Days))
+	}
+	// 未知类型17按类型ID保留
+	if v, ifSet := ret.Days[0]["17"]; !ifSet || v.Name != "新增指数" {
+		t.Errorf("Days[0][17] = %+v, want 新增指数", v)
+	}
+	if ret.Index.Dress.Category != "较舒适" {
+		t.Errorf("Index.Dress.Category = %q, want 较舒适", ret.Index.Dress.Category)
+	}
+
+	srv = newFixtureServer(t, map[string]string{"/indices/3d": "qweather/indices_3d_200.json"})
+	ret, _, err = GetWeatherIndexDays("101010100", srv.URL, "test", 3, RequestOptions{})
+	if err != nil {
+		t.Fatalf("GetWeatherIndexDays() error = %v", err)
+	}
+	if len(ret.Days) != 3 {
+		t.Fatalf("len(Days) = %d, want 3", len(ret.Days))
+	}
+	for i, date := range []string{"2026-10-19", "2026-10-20", "2026-10-21"} {
+		for typ, v := range ret.Days[i] {
+			if v.Date != date {
+				t.Errorf("Days[%d][%s].Date = %q, want %q", i, typ, v.Date, date)
+			}
+		}
+	}
+
+	srv = newFixtureServer(t, map[string]string{"/indices/1d": "qweather/indices_empty.json"})
+	ret, _, err = GetWeatherIndex("101010100", srv.URL, "test", RequestOptions{})
+	if err != nil {
+		t.Fatalf("GetWeatherIndex() with empty list error = %v", err)
+	}
+	if len(ret.Days) > 0 && len(ret.Days[0]) > 0 {
+		t.Errorf("Days = %v, want no indices", ret.Days)
+	}
+	if ret.Index.Dress.Category != "" {
+		t.Errorf("Index.Dress = %+v, want empty", ret.Index.Dress)
+	}
+}
+
+func TestSharedFixtures(t *testing.T) {
+	defer SetSharedEndpoint("")
+	tests := []struct {
+		file    string
+		want    error
+		message string
+	}{
+		{"shared/weather_200.json", nil, ""},
+		{"shared/weather_429.json", ErrTooManyRequests, "请求过于频繁,请稍后再试"},
+		{"shared/weather_empty.json", ErrMalformedResponse, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			srv := newFixtureServer(t, map[string]string{"/weather": tt.file})
+			SetSharedEndpoint(srv.URL + "/weather")
+			ret, err := GetWeather("101010100")
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("GetWeather() error = %v", err)
+				}
+				if ret.WeatherStatus.Temp != "18" || ret.WeatherIndexs.Dress.Category != "较舒适" {
+					t.Errorf("GetWeather() = %+v", ret)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("GetWeather() error = %v, want %v", err, tt.want)
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.Provider != ProviderShared {
+				t.Fatalf("GetWeather() error = %#v, want *APIError from %s", err, ProviderShared)
+			}
+			if tt.message != "" && apiErr.Message != tt.message {
+				t.Errorf("Message = %q, want %q", apiErr.Message, tt.message)
+			}
+		})
+	}
+}
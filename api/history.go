@@ -0,0 +1,224 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DaySummary 某一天的天气记录,温度统一使用摄氏度
+type DaySummary struct {
+	Date    string          `json:"date"`     // 日期,2006-01-02
+	TempMax float64         `json:"temp_max"` // 最高温度
+	TempMin float64         `json:"temp_min"` // 最低温度
+	Hours   map[int]float64 `json:"hours"`    // 逐小时温度,键为小时
+	Icon    string          `json:"icon"`     // 最后一次记录的天气图标
+	Text    string          `json:"text"`     // 最后一次记录的天气描述
+}
+
+// TempAt 获取最接近指定小时的温度
+//
+// maxDiff: 允许相差的最大小时数
+func (d DaySummary) TempAt(hour, maxDiff int) (float64, bool) {
+	best, bestDiff := 0.0, maxDiff+1
+	for h, v := range d.Hours {
+		diff := h - hour
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff < bestDiff {
+			best, bestDiff = v, diff
+		}
+	}
+	return best, bestDiff <= maxDiff
+}
+
+// add 加入一次温度记录
+func (d *DaySummary) add(hour int, temp float64) {
+	if d.Hours == nil {
+		d.Hours = make(map[int]float64)
+	}
+	if len(d.Hours) == 0 || temp > d.TempMax {
+		d.TempMax = temp
+	}
+	if len(d.Hours) == 0 || temp < d.TempMin {
+		d.TempMin = temp
+	}
+	d.Hours[hour] = temp
+}
+
+// HistoricalProvider 支持历史天气的数据源
+type HistoricalProvider interface {
+	GetHistorical(ctx context.Context, cityID string, date time.Time) (DaySummary, error)
+}
+
+// HistoryStore 本地历史天气,每次获取成功时记录,按城市保存为json文件
+type HistoryStore struct {
+	Path string // 保存目录
+	Days int    // 保留天数,为0时不清理
+
+	lock sync.Mutex
+}
+
+// NewHistoryStore 创建本地历史天气,默认保留400天以便与去年同日比较
+func NewHistoryStore(path string) *HistoryStore {
+	return &HistoryStore{
+		Path: path,
+		Days: 400,
+	}
+}
+
+func (s *HistoryStore) file(cityID string) string {
+	return filepath.Join(s.Path, cityFileName("history_", cityID, ".json"))
+}
+
+func (s *HistoryStore) load(cityID string) (map[string]DaySummary, error) {
+	ret := make(map[string]DaySummary)
+	data, err := os.ReadFile(s.file(cityID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ret, nil
+		}
+		return ret, err
+	}
+	err = json.Unmarshal(data, &ret)
+	return ret, err
+}
+
+func (s *HistoryStore) save(cityID string, days map[string]DaySummary) error {
+	data, err := json.Marshal(days)
+	if err != nil {
+		return err
+	}
+	tmp := s.file(cityID) + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file(cityID))
+}
+
+// Record 记录一次实时天气,缓存数据不记录
+func (s *HistoryStore) Record(cityID string, w Weather) error {
+	if w.Stale || !w.Now.Temp.Valid {
+		return nil
+	}
+	t := w.Now.ObsTime
+	if t.IsZero() {
+		t = time.Now()
+	}
+	t = t.Local()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	days, err := s.load(cityID)
+	if err != nil {
+		return err
+	}
+	date := t.Format("2006-01-02")
+	day := days[date]
+	day.Date = date
+	day.add(t.Hour(), w.Now.Temp.Convert(UnitCelsius).Value)
+	day.Icon = w.Now.Icon
+	day.Text = w.Now.Text
+	days[date] = day
+	s.prune(days, t)
+	return s.save(cityID, days)
+}
+
+// Put 保存一整天的记录,例如从历史天气接口获取的数据
+func (s *HistoryStore) Put(cityID string, day DaySummary) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	days, err := s.load(cityID)
+	if err != nil {
+		return err
+	}
+	days[day.Date] = day
+	s.prune(days, time.Now())
+	return s.save(cityID, days)
+}
+
+// Day 获取某一天的记录
+func (s *HistoryStore) Day(cityID string, date time.Time) (DaySummary, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	days, err := s.load(cityID)
+	if err != nil {
+		return DaySummary{}, false, err
+	}
+	day, ifSet := days[date.Format("2006-01-02")]
+	return day, ifSet, nil
+}
+
+// prune 删除超过保留天数的记录
+func (s *HistoryStore) prune(days map[string]DaySummary, now time.Time) {
+	if s.Days <= 0 {
+		return
+	}
+	oldest := now.AddDate(0, 0, -s.Days).Format("2006-01-02")
+	for date := range days {
+		if date < oldest {
+			delete(days, date)
+		}
+	}
+}
+
+type cityHistoricalRaw struct {
+	Code         string `json:"code"`
+	FxLink       string `json:"fxLink"`
+	WeatherDaily struct {
+		Date    string `json:"date"`
+		TempMax string `json:"tempMax"`
+		TempMin string `json:"tempMin"`
+	} `json:"weatherDaily"`
+	WeatherHourly []struct {
+		Time string `json:"time"`
+		Temp string `json:"temp"`
+		Icon string `json:"icon"`
+		Text string `json:"text"`
+	} `json:"weatherHourly"`
+}
+
+// GetHistoricalWeather 通过和风天气接口获取历史天气
+//
+// date: 日期,接口仅支持最近10天,不包含当天
+func GetHistoricalWeather(cityID, host, key string, date time.Time, opt RequestOptions) (ret DaySummary, err error) {
+	if len(key) == 0 && opt.Signer == nil {
+		return ret, errors.New("历史天气接口需要秘钥")
+	}
+	// 历史天气固定使用公制,本地记录统一为摄氏度
+	opt.Unit = "m"
+	raw := cityHistoricalRaw{}
+	rawURL := fmt.Sprintf("%s/historical/weather?location=%s&date=%s%s", host, url.QueryEscape(cityID), date.Format("20060102"), opt.query(key))
+	if err = getQWeatherJson(rawURL, opt, &raw, &raw.Code); err != nil {
+		return ret, err
+	}
+	ret.Date = date.Format("2006-01-02")
+	ret.TempMax, _ = strconv.ParseFloat(raw.WeatherDaily.TempMax, 64)
+	ret.TempMin, _ = strconv.ParseFloat(raw.WeatherDaily.TempMin, 64)
+	ret.Hours = make(map[int]float64)
+	sort.Slice(raw.WeatherHourly, func(i, j int) bool {
+		return raw.WeatherHourly[i].Time < raw.WeatherHourly[j].Time
+	})
+	for _, v := range raw.WeatherHourly {
+		t, err := time.Parse(qweatherTimeLayout, v.Time)
+		if err != nil {
+			continue
+		}
+		temp, err := strconv.ParseFloat(v.Temp, 64)
+		if err != nil {
+			continue
+		}
+		ret.Hours[t.Hour()] = temp
+		ret.Icon = v.Icon
+		ret.Text = v.Text
+	}
+	return ret, nil
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IndexType 生活指数类型
+type IndexType struct {
+	Type  string // 和风天气指数类型ID
+	Key   string // 配置中使用的名称,与 WeatherIndexs 的json字段一致
+	Name  string // 指数名称
+	Short string // 简称,用于小组件
+}
+
+// IndexTypes 所有支持的生活指数类型
+var IndexTypes = []IndexType{
+	{"1", "motion", "运动指数", "运动"},
+	{"2", "carWash", "洗车指数", "洗车"},
+	{"3", "dress", "穿衣指数", "穿衣"},
+	{"4", "fishing", "钓鱼指数", "钓鱼"},
+	{"5", "uv", "紫外线指数", "紫外线"},
+	{"6", "travel", "旅游指数", "旅游"},
+	{"7", "allergy", "过敏指数", "过敏"},
+	{"8", "comfort", "舒适度指数", "舒适度"},
+	{"9", "cold", "感冒指数", "感冒"},
+	{"10", "air", "空气污染扩散条件指数", "空气质量"},
+	{"11", "ac", "空调开启指数", "空调"},
+	{"12", "sunglass", "太阳镜指数", "太阳镜"},
+	{"13", "makeup", "化妆指数", "化妆"},
+	{"14", "dry", "晾晒指数", "晾晒"},
+	{"15", "traffic", "交通指数", "交通"},
+	{"16", "sunscreen", "防晒指数", "防晒"},
+}
+
+// GetIndexType 根据配置名称或类型ID获取生活指数类型
+//
+// 不在 IndexTypes 中的数字类型ID同样有效,名称使用接口返回的指数名称
+func GetIndexType(key string) (IndexType, bool) {
+	for _, v := range IndexTypes {
+		if strings.EqualFold(v.Key, key) || v.Type == key {
+			return v, true
+		}
+	}
+	if _, err := strconv.Atoi(key); err == nil {
+		return IndexType{Type: key, Key: key}, true
+	}
+	return IndexType{}, false
+}
+
+// IndexSet 某一天的生活指数,键为指数类型ID,包含未知类型
+type IndexSet map[string]WeatherIndexStatus
+
+// NewIndexSet 由固定字段的生活指数创建,跳过没有数据的指数
+func NewIndexSet(indexs WeatherIndexs) IndexSet {
+	ret := make(IndexSet)
+	for _, t := range IndexTypes {
+		v := *indexs.Get(t.Type)
+		if v.Category == "" {
+			continue
+		}
+		v.Type = t.Type
+		ret[t.Type] = v
+	}
+	return ret
+}
+
+// Indexs 转换为固定字段的生活指数,未知类型被忽略
+func (s IndexSet) Indexs() (ret WeatherIndexs) {
+	for typ, v := range s {
+		if p := ret.Get(typ); p != nil {
+			*p = v
+		}
+	}
+	return ret
+}
+
+// Label 指数显示名称,已知类型使用简称,未知类型使用接口返回的名称
+func (v WeatherIndexStatus) Label() string {
+	if t, ok := GetIndexType(v.Type); ok && t.Short != "" {
+		return t.Short
+	}
+	return strings.TrimSuffix(v.Name, "指数")
+}
+
+// ParseIndexTypes 解析以逗号分隔的生活指数列表,例如"dress,uv,cold"
+func ParseIndexTypes(s string) ([]IndexType, error) {
+	var ret []IndexType
+	for _, key := range strings.Split(s, ",") {
+		if key = strings.TrimSpace(key); key == "" {
+			continue
+		}
+		t, ok := GetIndexType(key)
+		if !ok {
+			return nil, fmt.Errorf("未知生活指数:%s", key)
+		}
+		ret = append(ret, t)
+	}
+	return ret, nil
+}
+
+// Get 根据类型ID获取生活指数
+func (w *WeatherIndexs) Get(typ string) *WeatherIndexStatus {
+	switch typ {
+	case "1":
+		return &w.Motion
+	case "2":
+		return &w.CarWash
+	case "3":
+		return &w.Dress
+	case "4":
+		return &w.Fishing
+	case "5":
+		return &w.UV
+	case "6":
+		return &w.Travel
+	case "7":
+		return &w.Allergy
+	case "8":
+		return &w.Comfort
+	case "9":
+		return &w.Cold
+	case "10":
+		return &w.Air
+	case "11":
+		return &w.Ac
+	case "12":
+		return &w.Sunglass
+	case "13":
+		return &w.Makeup
+	case "14":
+		return &w.Dry
+	case "15":
+		return &w.Traffic
+	case "16":
+		return &w.Sunscreen
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSigner 和风天气JWT签名器,生成的令牌在有效期内复用
+type TokenSigner struct {
+	ProjectID string        // 项目ID
+	KeyID     string        // 凭据ID
+	TTL       time.Duration // 令牌有效期,和风天气最长24小时
+
+	privateKey ed25519.PrivateKey
+	lock       sync.Mutex
+	token      string
+	expires    time.Time
+}
+
+// NewTokenSigner 创建JWT签名器
+//
+// privateKey: PEM格式的Ed25519私钥
+func NewTokenSigner(projectID, keyID, privateKey string) (*TokenSigner, error) {
+	if projectID == "" || keyID == "" {
+		return nil, errors.New("项目ID与凭据ID不能为空")
+	}
+	block, _ := pem.Decode([]byte(strings.TrimSpace(privateKey)))
+	if block == nil {
+		return nil, errors.New("私钥格式错误,需要PEM格式")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("私钥类型错误,需要Ed25519私钥")
+	}
+	return &TokenSigner{
+		ProjectID:  projectID,
+		KeyID:      keyID,
+		TTL:        15 * time.Minute,
+		privateKey: edKey,
+	}, nil
+}
+
+// Token 获取令牌,剩余有效期不足1分钟时重新签发
+func (s *TokenSigner) Token() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	if s.token != "" && now.Add(time.Minute).Before(s.expires) {
+		return s.token, nil
+	}
+	// 签发时间提前30秒,避免本地时间误差导致令牌未生效
+	iat := now.Add(-30 * time.Second)
+	exp := iat.Add(s.TTL)
+	header, err := json.Marshal(map[string]string{
+		"alg": "EdDSA",
+		"kid": s.KeyID,
+	})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(map[string]any{
+		"sub": s.ProjectID,
+		"iat": iat.Unix(),
+		"exp": exp.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	data := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	sign := ed25519.Sign(s.privateKey, []byte(data))
+	s.token = data + "." + enc.EncodeToString(sign)
+	s.expires = exp
+	return s.token, nil
+}
+
+// tokenKeyID 从Authorization请求头中取出JWT的凭据ID
+func tokenKeyID(auth string) string {
+	token, ok := strings.CutPrefix(auth, "Bearer ")
+	if !ok {
+		return ""
+	}
+	header, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return ""
+	}
+	data, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return ""
+	}
+	var v struct {
+		Kid string `json:"kid"`
+	}
+	if json.Unmarshal(data, &v) != nil {
+		return ""
+	}
+	return v.Kid
+}
+
+// NormalizeQWeatherHost 规范化和风天气自定义接口地址
+//
+// 未填写协议时补全https,未填写路径时补全/v7
+func NormalizeQWeatherHost(host string) string {
+	host = strings.TrimRight(strings.TrimSpace(host), "/")
+	if host == "" {
+		return ""
+	}
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	if rest := host[strings.Index(host, "://")+3:]; !strings.Contains(rest, "/") {
+		host += "/v7"
+	}
+	return host
+}
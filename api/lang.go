@@ -0,0 +1,46 @@
+package api
+
+import "strings"
+
+// IsChinese 判断语言是否为中文,为空时视为中文
+func IsChinese(lang string) bool {
+	return lang == "" || strings.HasPrefix(strings.ToLower(lang), "zh")
+}
+
+// WMO 天气代码的英文描述
+var wmoTextsEn = map[int]string{
+	0:  "Clear",
+	1:  "Mainly clear",
+	2:  "Partly cloudy",
+	3:  "Overcast",
+	45: "Fog",
+	48: "Rime fog",
+	51: "Light drizzle",
+	53: "Drizzle",
+	55: "Dense drizzle",
+	56: "Freezing drizzle",
+	57: "Freezing drizzle",
+	61: "Light rain",
+	63: "Rain",
+	65: "Heavy rain",
+	66: "Freezing rain",
+	67: "Freezing rain",
+	71: "Light snow",
+	73: "Snow",
+	75: "Heavy snow",
+	77: "Snow grains",
+	80: "Showers",
+	81: "Showers",
+	82: "Heavy showers",
+	85: "Snow showers",
+	86: "Snow showers",
+	95: "Thunderstorm",
+	96: "Thunderstorm, hail",
+	99: "Thunderstorm, hail",
+}
+
+// 八方位风向
+var (
+	windDirsZh = []string{"北风", "东北风", "东风", "东南风", "南风", "西南风", "西风", "西北风"}
+	windDirsEn = []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+)
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Unit 计量单位
+type Unit string
+
+const (
+	UnitCelsius    Unit = "°C"
+	UnitFahrenheit Unit = "°F"
+	UnitPercent    Unit = "%"
+	UnitKmh        Unit = "km/h"
+	UnitHPa        Unit = "hPa"
+	UnitKm         Unit = "km"
+	UnitMm         Unit = "mm"
+	UnitDegree     Unit = "°"
+	UnitBeaufort   Unit = "级"
+)
+
+// Quantity 带单位的数值
+type Quantity struct {
+	Value float64
+	Unit  Unit
+	Valid bool // 接口未返回或无法解析时为false
+}
+
+// ParseQuantity 解析接口返回的数值字符串
+func ParseQuantity(s string, unit Unit) Quantity {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return Quantity{Unit: unit}
+	}
+	return Quantity{Value: v, Unit: unit, Valid: true}
+}
+
+// Format 格式化数值,不带单位,无效时返回"--"
+//
+// prec: 小数位数
+func (q Quantity) Format(prec int) string {
+	if !q.Valid {
+		return "--"
+	}
+	return strconv.FormatFloat(q.Value, 'f', prec, 64)
+}
+
+// String 按单位的显示精度格式化数值并带上单位,无效时返回"--"
+func (q Quantity) String() string {
+	if !q.Valid {
+		return "--"
+	}
+	return q.Format(q.Unit.precision()) + string(q.Unit)
+}
+
+// precision 单位的显示小数位数
+//
+// 英寸汞柱与英寸的数值较小,取整会丢失大部分信息
+func (u Unit) precision() int {
+	switch u {
+	case UnitInHg, UnitInch:
+		return 2
+	case UnitMm, UnitMs, UnitMile:
+		return 1
+	}
+	return 0
+}
+
+// Observation 解析后的实时天气
+type Observation struct {
+	ObsTime   time.Time // 数据观测时间,无法解析时为零值
+	Temp      Quantity  // 温度
+	FeelsLike Quantity  // 体感温度
+	Icon      string    // 天气状况图标代码
+	Text      string    // 天气状况的文字描述
+	Wind360   Quantity  // 风向360角度
+	WindDir   string    // 风向
+	WindScale Quantity  // 风力等级
+	WindSpeed Quantity  // 风速
+	Humidity  Quantity  // 相对湿度
+	Precip    Quantity  // 当前小时累计降水量
+	Pressure  Quantity  // 大气压强
+	Vis       Quantity  // 能见度
+	Cloud     Quantity  // 云量
+	Dew       Quantity  // 露点温度
+}
+
+// ParseObservation 将接口返回的字符串数据解析为数值
+//
+// units: 接口返回数据使用的单位,未设置的项按公制处理
+func ParseObservation(s WeatherStatus, units UnitSystem) Observation {
+	units = units.withDefault()
+	ret := Observation{
+		Temp:      ParseQuantity(s.Temp, units.Temperature),
+		FeelsLike: ParseQuantity(s.FeelsLike, units.Temperature),
+		Icon:      s.Icon,
+		Text:      s.Text,
+		Wind360:   ParseQuantity(s.Wind360, UnitDegree),
+		WindDir:   s.WindDir,
+		WindScale: ParseQuantity(s.WindScale, UnitBeaufort),
+		WindSpeed: ParseQuantity(s.WindSpeed, units.WindSpeed),
+		Humidity:  ParseQuantity(s.Humidity, UnitPercent),
+		Precip:    ParseQuantity(s.Precip, units.Precip),
+		Pressure:  ParseQuantity(s.Pressure, units.Pressure),
+		Vis:       ParseQuantity(s.Vis, units.Visibility),
+		Cloud:     ParseQuantity(s.Cloud, UnitPercent),
+		Dew:       ParseQuantity(s.Dew, units.Temperature),
+	}
+	// 风力等级可能为"3-4"这样的范围,取较大值
+	if !ret.WindScale.Valid {
+		if _, high, ok := strings.Cut(s.WindScale, "-"); ok {
+			ret.WindScale = ParseQuantity(high, UnitBeaufort)
+		}
+	}
+	for _, layout := range []string{qweatherTimeLayout, time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s.ObsTime, time.Local); err == nil {
+			ret.ObsTime = t
+			break
+		}
+	}
+	return ret
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/csv"
+	"hw_weather_plugin/utils/sqlite"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 导出的实时天气列,数值均为公制
+var observationColumns = []string{
+	"time", "city_id", "provider", "obs_time",
+	"temp_c", "feels_like_c", "icon", "text",
+	"wind_360", "wind_dir", "wind_scale", "wind_speed_kmh",
+	"humidity", "precip_mm", "pressure_hpa", "vis_km", "cloud", "dew_c",
+}
+
+// observationRow 将记录转为导出的一行,无效数值为nil
+func observationRow(r ObservationRecord) []any {
+	o := r.Observation()
+	num := func(q Quantity) any {
+		if !q.Valid {
+			return nil
+		}
+		// 换算后保留两位小数,避免 -5.555555555555555 之类的长尾
+		return math.Round(q.Value*100) / 100
+	}
+	obsTime := any(nil)
+	if !o.ObsTime.IsZero() {
+		obsTime = o.ObsTime.Format(time.RFC3339)
+	}
+	return []any{
+		r.Time.Local().Format(time.RFC3339), r.CityID, r.Provider, obsTime,
+		num(o.Temp), num(o.FeelsLike), o.Icon, o.Text,
+		num(o.Wind360), o.WindDir, num(o.WindScale), num(o.WindSpeed),
+		num(o.Humidity), num(o.Precip), num(o.Pressure), num(o.Vis), num(o.Cloud), num(o.Dew),
+	}
+}
+
+// WriteObservationsCSV 将天气记录导出为CSV
+//
+// 每个已知生活指数占一列,值为指数等级描述
+func WriteObservationsCSV(w io.Writer, records []ObservationRecord) error {
+	cw := csv.NewWriter(w)
+	header := append([]string{}, observationColumns...)
+	for _, t := range IndexTypes {
+		header = append(header, "index_"+t.Key)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, r := range records {
+		row := make([]string, 0, len(header))
+		for _, v := range observationRow(r) {
+			switch v := v.(type) {
+			case nil:
+				row = append(row, "")
+			case float64:
+				row = append(row, strconv.FormatFloat(v, 'f', -1, 64))
+			default:
+				row = append(row, v.(string))
+			}
+		}
+		for _, t := range IndexTypes {
+			row = append(row, r.Indices[t.Type].Category)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteObservationsSQLite 将天气记录导出为SQLite数据库
+//
+// observations 表每条记录一行,indices 表保存每条记录的全部生活指数,
+// 通过 observation_id 关联 observations.id
+func WriteObservationsSQLite(w io.Writer, records []ObservationRecord) error {
+	obs := sqlite.Table{
+		Name: "observations",
+		SQL: "CREATE TABLE observations (id INTEGER PRIMARY KEY, time TEXT, city_id TEXT, provider TEXT, obs_time TEXT, " +
+			"temp_c REAL, feels_like_c REAL, icon TEXT, text TEXT, wind_360 REAL, wind_dir TEXT, wind_scale REAL, wind_speed_kmh REAL, " +
+			"humidity REAL, precip_mm REAL, pressure_hpa REAL, vis_km REAL, cloud REAL, dew_c REAL)",
+	}
+	indices := sqlite.Table{
+		Name: "indices",
+		SQL:  "CREATE TABLE indices (id INTEGER PRIMARY KEY, observation_id INTEGER, type TEXT, name TEXT, level TEXT, category TEXT, text TEXT)",
+	}
+	for i, r := range records {
+		// id 列即rowid,按行号自动编号
+		obs.Rows = append(obs.Rows, append([]any{nil}, observationRow(r)...))
+		types := make([]string, 0, len(r.Indices))
+		for typ := range r.Indices {
+			types = append(types, typ)
+		}
+		// 按类型ID数值排序,保证导出顺序稳定
+		sort.Slice(types, func(a, b int) bool {
+			x, _ := strconv.Atoi(types[a])
+			y, _ := strconv.Atoi(types[b])
+			return x < y
+		})
+		for _, typ := range types {
+			v := r.Indices[typ]
+			indices.Rows = append(indices.Rows, []any{nil, int64(i + 1), typ, v.Name, v.Level, v.Category, v.Text})
+		}
+	}
+	return sqlite.Write(w, obs, indices)
+}
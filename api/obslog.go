@@ -0,0 +1,237 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ObservationRecord 一次实时天气记录
+type ObservationRecord struct {
+	Time     time.Time     `json:"time"`     // 获取时间
+	CityID   string        `json:"city_id"`  // 城市ID
+	Provider string        `json:"provider"` // 实际使用的数据源
+	Units    UnitSystem    `json:"units"`    // Current 使用的单位,为空表示公制
+	Current  WeatherStatus `json:"current"`  // 实时天气,接口返回的原始数据
+	Indices  IndexSet      `json:"indices"`  // 当天的生活指数,数据源不支持时为空
+}
+
+// Observation 将记录解析为数值,单位为公制
+func (r ObservationRecord) Observation() Observation {
+	return MetricUnits.Apply(ParseObservation(r.Current, r.Units))
+}
+
+// ObservationLog 本地天气记录,每次获取成功时追加一行,按城市保存为jsonl文件
+type ObservationLog struct {
+	Path       string // 保存目录
+	Days       int    // 保留天数,为0时不按时间清理
+	MaxRecords int    // 每个城市最多保留的记录数,为0时不限制
+
+	lock   sync.Mutex
+	pruned map[string]string // 各城市最后一次清理的日期
+	lines  map[string]int    // 各城市文件中的行数,首次追加时统计
+}
+
+// NewObservationLog 创建本地天气记录,默认保留一年
+func NewObservationLog(path string) *ObservationLog {
+	return &ObservationLog{
+		Path: path,
+		Days: 365,
+	}
+}
+
+func (l *ObservationLog) file(cityID string) string {
+	return filepath.Join(l.Path, cityFileName("observations_", cityID, ".jsonl"))
+}
+
+// 按记录数清理前允许超出的比例,超出 MaxRecords 的该比例后才重写文件
+const observationSlack = 10
+
+// Append 追加一次实时天气,缓存数据不记录
+//
+// 每个城市每天最多清理一次过期记录,避免每次刷新都重写文件;
+// 设置 MaxRecords 时,行数超出上限的 1/observationSlack 后也会清理
+func (l *ObservationLog) Append(cityID string, w Weather) error {
+	if w.Stale || w.Current.Temp == "" {
+		return nil
+	}
+	record := ObservationRecord{
+		Time:     time.Now(),
+		CityID:   cityID,
+		Provider: w.Provider,
+		Units:    w.Units,
+		Current:  w.Current,
+		Indices:  w.IndicesOn(0),
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.lines == nil {
+		l.lines = make(map[string]int)
+	}
+	if _, ifSet := l.lines[cityID]; !ifSet {
+		if l.lines[cityID], err = l.count(cityID); err != nil {
+			delete(l.lines, cityID)
+			return err
+		}
+	}
+	f, err := os.OpenFile(l.file(cityID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	l.lines[cityID]++
+	today := record.Time.Format("2006-01-02")
+	over := l.MaxRecords > 0 && l.lines[cityID] > l.MaxRecords+l.MaxRecords/observationSlack
+	if l.pruned[cityID] == today && !over {
+		return nil
+	}
+	if l.pruned == nil {
+		l.pruned = make(map[string]string)
+	}
+	l.pruned[cityID] = today
+	return l.prune(cityID, record.Time)
+}
+
+// Records 读取城市在时间范围内的记录,from或to为零值时不限制
+//
+// 无法解析的行会被跳过,例如写入中断留下的半行
+func (l *ObservationLog) Records(cityID string, from, to time.Time) ([]ObservationRecord, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	all, _, err := l.load(cityID)
+	if err != nil {
+		return nil, err
+	}
+	// 按记录数清理前文件中可能有少量超出上限的旧记录
+	if l.MaxRecords > 0 && len(all) > l.MaxRecords {
+		all = all[len(all)-l.MaxRecords:]
+	}
+	ret := make([]ObservationRecord, 0, len(all))
+	for _, r := range all {
+		if (!from.IsZero() && r.Time.Before(from)) || (!to.IsZero() && !r.Time.Before(to)) {
+			continue
+		}
+		ret = append(ret, r)
+	}
+	return ret, nil
+}
+
+// Prune 立即按保留天数与记录数清理城市的记录
+func (l *ObservationLog) Prune(cityID string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.prune(cityID, time.Now())
+}
+
+// load 读取城市的全部记录,同时返回文件中的行数,包括空行与无法解析的行
+func (l *ObservationLog) load(cityID string) (ret []ObservationRecord, lines int, err error) {
+	f, err := os.Open(l.file(cityID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var r ObservationRecord
+		if json.Unmarshal(line, &r) == nil {
+			ret = append(ret, r)
+		}
+	}
+	return ret, lines, scanner.Err()
+}
+
+// count 统计城市文件中的行数
+func (l *ObservationLog) count(cityID string) (int, error) {
+	f, err := os.Open(l.file(cityID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer f.Close()
+	ret := 0
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := f.Read(buf)
+		ret += bytes.Count(buf[:n], []byte{'\n'})
+		if err == io.EOF {
+			return ret, nil
+		}
+		if err != nil {
+			return ret, err
+		}
+	}
+}
+
+// prune 删除超过保留天数与记录数的旧记录以及无法解析的行,文件无需改动时不重写
+func (l *ObservationLog) prune(cityID string, now time.Time) error {
+	if l.Days <= 0 && l.MaxRecords <= 0 {
+		return nil
+	}
+	records, lines, err := l.load(cityID)
+	if err != nil {
+		return err
+	}
+	keep := records
+	if l.Days > 0 {
+		oldest := now.AddDate(0, 0, -l.Days)
+		for len(keep) > 0 && keep[0].Time.Before(oldest) {
+			keep = keep[1:]
+		}
+	}
+	if l.MaxRecords > 0 && len(keep) > l.MaxRecords {
+		keep = keep[len(keep)-l.MaxRecords:]
+	}
+	if len(keep) == lines {
+		if l.lines != nil {
+			l.lines[cityID] = lines
+		}
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, r := range keep {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	// 先写临时文件再替换,避免写入中断丢失全部记录
+	tmp := l.file(cityID) + ".tmp"
+	if err = os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, l.file(cityID)); err != nil {
+		return err
+	}
+	if l.lines != nil {
+		l.lines[cityID] = len(keep)
+	}
+	return nil
+}
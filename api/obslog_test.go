@@ -0,0 +1,71 @@
+package api
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestObservationLogMaxRecords(t *testing.T) {
+	l := &ObservationLog{Path: t.TempDir(), MaxRecords: 20}
+	lines := func() int {
+		data, err := os.ReadFile(l.file("101010100"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bytes.Count(data, []byte{'\n'})
+	}
+	for i := 0; i < 51; i++ {
+		if err := l.Append("101010100", Weather{Current: WeatherStatus{Temp: strconv.Itoa(i)}}); err != nil {
+			t.Fatal(err)
+		}
+		// 当天已清理过时,未超出余量前不重写文件
+		if n := lines(); n > l.MaxRecords+l.MaxRecords/observationSlack {
+			t.Fatalf("after %d appends file has %d lines", i+1, n)
+		}
+	}
+	if n := lines(); n == l.MaxRecords {
+		t.Errorf("file has %d lines, want a few more before pruning again", n)
+	}
+	records, err := l.Records("101010100", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != l.MaxRecords || records[0].Current.Temp != "31" || records[len(records)-1].Current.Temp != "50" {
+		t.Errorf("Records() = %d records from %s to %s, want 20 from 31 to 50",
+			len(records), records[0].Current.Temp, records[len(records)-1].Current.Temp)
+	}
+}
+
+func TestObservationLogPruneCorrupt(t *testing.T) {
+	l := &ObservationLog{Path: t.TempDir(), Days: 365}
+	for i := 0; i < 3; i++ {
+		if err := l.Append("101010100", Weather{Current: WeatherStatus{Temp: strconv.Itoa(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 写入中断留下的半行与空行,记录都未过期
+	f, err := os.OpenFile(l.file("101010100"), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{\"time\":\n\n")
+	f.Close()
+	l.lines["101010100"] += 2
+
+	if err := l.Prune("101010100"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(l.file("101010100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte{'\n'}); n != 3 {
+		t.Errorf("file has %d lines after Prune(), want 3", n)
+	}
+	if l.lines["101010100"] != 3 {
+		t.Errorf("lines = %d, want 3", l.lines["101010100"])
+	}
+}
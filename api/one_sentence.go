@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"io"
 	"math/rand"
-	"net/http"
 )
 
 type OneSentenceData struct {
@@ -31,7 +30,7 @@ var (
 // GetOneSentence 用于获取一言
 func GetOneSentence() (OneSentenceData, error) {
 	ret := OneSentenceData{}
-	req, err := http.Get("https://v1.hitokoto.cn/?c=i&max_length=16&min_length=15")
+	req, err := httpClient.Get("https://v1.hitokoto.cn/?c=i&max_length=16&min_length=15")
 	if err != nil {
 		return ret, err
 	}
@@ -0,0 +1,318 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hw_weather_plugin/utils/utils"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Open-Meteo 接口地址
+const openMeteoHost = "https://api.open-meteo.com/v1"
+
+// WMO 天气代码对应的和风天气图标与文字
+var wmoCodes = map[int]struct {
+	Icon string
+	Text string
+}{
+	0:  {"100", "晴"},
+	1:  {"103", "晴间多云"},
+	2:  {"101", "多云"},
+	3:  {"104", "阴"},
+	45: {"501", "雾"},
+	48: {"501", "雾"},
+	51: {"309", "毛毛雨"},
+	53: {"309", "毛毛雨"},
+	55: {"309", "毛毛雨"},
+	56: {"313", "冻雨"},
+	57: {"313", "冻雨"},
+	61: {"305", "小雨"},
+	63: {"306", "中雨"},
+	65: {"307", "大雨"},
+	66: {"313", "冻雨"},
+	67: {"313", "冻雨"},
+	71: {"400", "小雪"},
+	73: {"401", "中雪"},
+	75: {"402", "大雪"},
+	77: {"499", "雪"},
+	80: {"300", "阵雨"},
+	81: {"300", "阵雨"},
+	82: {"301", "强阵雨"},
+	85: {"407", "阵雪"},
+	86: {"407", "阵雪"},
+	95: {"302", "雷阵雨"},
+	96: {"304", "雷阵雨伴有冰雹"},
+	99: {"304", "雷阵雨伴有冰雹"},
+}
+
+// WMOIcon 将 WMO 天气代码转换为和风天气图标代码与文字
+//
+// lang: 文字的语言,非中文时使用英文
+func WMOIcon(code int, isDay bool, lang string) (icon, text string) {
+	v, ifSet := wmoCodes[code]
+	if !ifSet {
+		return "999", utils.Ifs(IsChinese(lang), "未知", "Unknown")
+	}
+	text = utils.Ifs(IsChinese(lang), v.Text, wmoTextsEn[code])
+	if !isDay {
+		return NightIcon(v.Icon), text
+	}
+	return v.Icon, text
+}
+
+// 蒲福风级上限,单位:公里/小时
+var beaufortScale = []float64{1, 6, 12, 20, 29, 39, 50, 62, 75, 89, 103, 118}
+
+// WindScale 根据风速计算风力等级
+//
+// speed: 风速,公里/小时
+func WindScale(speed float64) int {
+	for i, v := range beaufortScale {
+		if speed < v {
+			return i
+		}
+	}
+	return len(beaufortScale)
+}
+
+// WindDir 根据风向角度获取风向名称
+//
+// lang: 名称的语言,非中文时使用英文缩写
+func WindDir(deg float64, lang string) string {
+	dirs := utils.Ifs(IsChinese(lang), windDirsZh, windDirsEn)
+	return dirs[int(math.Floor(normalizeRange(deg, 360)/45+0.5))%8]
+}
+
+// openMeteoRaw Open-Meteo 响应,数值在无法获取时为null,使用指针区分
+type openMeteoRaw struct {
+	Error   bool   `json:"error"`
+	Reason  string `json:"reason"`
+	Current struct {
+		Time                string   `json:"time"`
+		Temperature2m       *float64 `json:"temperature_2m"`
+		RelativeHumidity2m  *float64 `json:"relative_humidity_2m"`
+		ApparentTemperature *float64 `json:"apparent_temperature"`
+		IsDay               int      `json:"is_day"`
+		Precipitation       *float64 `json:"precipitation"`
+		WeatherCode         int      `json:"weather_code"`
+		CloudCover          *float64 `json:"cloud_cover"`
+		PressureMsl         *float64 `json:"pressure_msl"`
+		WindSpeed10m        *float64 `json:"wind_speed_10m"`
+		WindDirection10m    *float64 `json:"wind_direction_10m"`
+		Visibility          *float64 `json:"visibility"`
+		DewPoint2m          *float64 `json:"dew_point_2m"`
+	} `json:"current"`
+	Hourly struct {
+		Time                     []string   `json:"time"`
+		Temperature2m            []*float64 `json:"temperature_2m"`
+		RelativeHumidity2m       []*float64 `json:"relative_humidity_2m"`
+		DewPoint2m               []*float64 `json:"dew_point_2m"`
+		PrecipitationProbability []*float64 `json:"precipitation_probability"`
+		Precipitation            []*float64 `json:"precipitation"`
+		WeatherCode              []int      `json:"weather_code"`
+		PressureMsl              []*float64 `json:"pressure_msl"`
+		CloudCover               []*float64 `json:"cloud_cover"`
+		WindSpeed10m             []*float64 `json:"wind_speed_10m"`
+		WindDirection10m         []*float64 `json:"wind_direction_10m"`
+		IsDay                    []int      `json:"is_day"`
+	} `json:"hourly"`
+	Daily struct {
+		Time                     []string   `json:"time"`
+		WeatherCode              []int      `json:"weather_code"`
+		Temperature2mMax         []*float64 `json:"temperature_2m_max"`
+		Temperature2mMin         []*float64 `json:"temperature_2m_min"`
+		Sunrise                  []string   `json:"sunrise"`
+		Sunset                   []string   `json:"sunset"`
+		PrecipitationSum         []*float64 `json:"precipitation_sum"`
+		WindSpeed10mMax          []*float64 `json:"wind_speed_10m_max"`
+		WindDirection10mDominant []*float64 `json:"wind_direction_10m_dominant"`
+		UvIndexMax               []*float64 `json:"uv_index_max"`
+	} `json:"daily"`
+}
+
+// OpenMeteoProvider Open-Meteo 数据源,无需秘钥
+type OpenMeteoProvider struct {
+	Host  string // 接口地址
+	Hours int    // 逐小时预报小时数
+	Days  int    // 逐天预报天数
+	Lang  string // 天气描述的语言,接口本身不返回文字,由内置中英文描述生成
+}
+
+// NewOpenMeteoProvider 创建 Open-Meteo 数据源
+func NewOpenMeteoProvider() *OpenMeteoProvider {
+	return &OpenMeteoProvider{
+		Host:  openMeteoHost,
+		Hours: 24,
+		Days:  3,
+	}
+}
+
+func (p *OpenMeteoProvider) Name() string {
+	return ProviderOpenMeteo
+}
+
+func (p *OpenMeteoProvider) GetWeather(ctx context.Context, cityID string) (ret Weather, err error) {
+	loc, err := GetLocation(cityID)
+	if err != nil {
+		return ret, errors.New("城市ID不存在\n请注意,Open-Meteo仅支持内置城市列表")
+	}
+	url := fmt.Sprintf("%s/forecast?latitude=%s&longitude=%s&timezone=auto&forecast_days=%d&forecast_hours=%d"+
+		"&current=temperature_2m,relative_humidity_2m,apparent_temperature,is_day,precipitation,weather_code,cloud_cover,pressure_msl,wind_speed_10m,wind_direction_10m,visibility,dew_point_2m"+
+		"&hourly=temperature_2m,relative_humidity_2m,dew_point_2m,precipitation_probability,precipitation,weather_code,pressure_msl,cloud_cover,wind_speed_10m,wind_direction_10m,is_day"+
+		"&daily=weather_code,temperature_2m_max,temperature_2m_min,sunrise,sunset,precipitation_sum,wind_speed_10m_max,wind_direction_10m_dominant,uv_index_max",
+		p.Host,
+		loc.Latitude,
+		loc.Longitude,
+		p.Days,
+		p.Hours,
+	)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return ret, err
+	}
+	respData, status, err := doRequest(req)
+	if err != nil {
+		return ret, err
+	}
+	raw := openMeteoRaw{}
+	err = json.Unmarshal(respData, &raw)
+	if err != nil {
+		return ret, newDecodeError(p.Name(), url, status, err)
+	}
+	if raw.Error {
+		err = newAPIError(p.Name(), url, status, "")
+		if err == nil {
+			err = &APIError{Provider: p.Name(), Endpoint: endpointOf(url), Status: status, Err: ErrBadRequest}
+		}
+		err.(*APIError).Message = raw.Reason
+		return ret, err
+	}
+	ret.Provider = p.Name()
+	ret.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+	ret.Current = convertOpenMeteoCurrent(&raw, p.Lang)
+	ret.Hourly = convertOpenMeteoHourly(&raw, p.Lang)
+	ret.Forecast = convertOpenMeteoDaily(&raw, p.Lang)
+	ret.parse()
+	return ret, nil
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatRound(v float64) string {
+	return strconv.Itoa(int(math.Round(v)))
+}
+
+// roundOrEmpty 四舍五入为整数,值为null时为空
+func roundOrEmpty(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return formatRound(*v)
+}
+
+// floatOrEmpty 格式化数值,值为null时为空
+func floatOrEmpty(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return formatFloat(*v)
+}
+
+// windOrEmpty 由风速与风向生成风向名称、风力等级与风速,风速为null时均为空
+func windOrEmpty(speed, deg *float64, lang string) (wind360, dir, scale, speedStr string) {
+	if deg != nil {
+		wind360, dir = formatRound(*deg), WindDir(*deg, lang)
+	}
+	if speed != nil {
+		scale, speedStr = strconv.Itoa(WindScale(*speed)), formatRound(*speed)
+	}
+	return wind360, dir, scale, speedStr
+}
+
+func minLen(lens ...int) int {
+	ret := lens[0]
+	for _, l := range lens[1:] {
+		if l < ret {
+			ret = l
+		}
+	}
+	return ret
+}
+
+func convertOpenMeteoCurrent(raw *openMeteoRaw, lang string) (ret WeatherStatus) {
+	c := raw.Current
+	ret.ObsTime = c.Time
+	ret.Temp = roundOrEmpty(c.Temperature2m)
+	ret.FeelsLike = roundOrEmpty(c.ApparentTemperature)
+	ret.Icon, ret.Text = WMOIcon(c.WeatherCode, c.IsDay == 1, lang)
+	ret.Wind360, ret.WindDir, ret.WindScale, ret.WindSpeed = windOrEmpty(c.WindSpeed10m, c.WindDirection10m, lang)
+	ret.Humidity = roundOrEmpty(c.RelativeHumidity2m)
+	ret.Precip = floatOrEmpty(c.Precipitation)
+	ret.Pressure = roundOrEmpty(c.PressureMsl)
+	// 能见度单位为米
+	if c.Visibility != nil {
+		ret.Vis = formatRound(*c.Visibility / 1000)
+	}
+	ret.Cloud = roundOrEmpty(c.CloudCover)
+	ret.Dew = roundOrEmpty(c.DewPoint2m)
+	return ret
+}
+
+func convertOpenMeteoHourly(raw *openMeteoRaw, lang string) (ret []HourlyForecast) {
+	h := raw.Hourly
+	// 接口按字段返回数组,长度不一致时按最短的处理
+	n := minLen(len(h.Time), len(h.Temperature2m), len(h.RelativeHumidity2m), len(h.DewPoint2m),
+		len(h.PrecipitationProbability), len(h.Precipitation), len(h.WeatherCode), len(h.PressureMsl),
+		len(h.CloudCover), len(h.WindSpeed10m), len(h.WindDirection10m), len(h.IsDay))
+	for i := 0; i < n; i++ {
+		item := HourlyForecast{
+			FxTime:   h.Time[i],
+			Temp:     roundOrEmpty(h.Temperature2m[i]),
+			Humidity: roundOrEmpty(h.RelativeHumidity2m[i]),
+			Pop:      roundOrEmpty(h.PrecipitationProbability[i]),
+			Precip:   floatOrEmpty(h.Precipitation[i]),
+			Pressure: roundOrEmpty(h.PressureMsl[i]),
+			Cloud:    roundOrEmpty(h.CloudCover[i]),
+			Dew:      roundOrEmpty(h.DewPoint2m[i]),
+		}
+		item.Wind360, item.WindDir, item.WindScale, item.WindSpeed = windOrEmpty(h.WindSpeed10m[i], h.WindDirection10m[i], lang)
+		item.Icon, item.Text = WMOIcon(h.WeatherCode[i], h.IsDay[i] == 1, lang)
+		ret = append(ret, item)
+	}
+	return ret
+}
+
+func convertOpenMeteoDaily(raw *openMeteoRaw, lang string) (ret []DailyForecast) {
+	d := raw.Daily
+	n := minLen(len(d.Time), len(d.WeatherCode), len(d.Temperature2mMax), len(d.Temperature2mMin),
+		len(d.Sunrise), len(d.Sunset), len(d.PrecipitationSum), len(d.WindSpeed10mMax),
+		len(d.WindDirection10mDominant), len(d.UvIndexMax))
+	for i := 0; i < n; i++ {
+		item := DailyForecast{
+			FxDate:  d.Time[i],
+			TempMax: roundOrEmpty(d.Temperature2mMax[i]),
+			TempMin: roundOrEmpty(d.Temperature2mMin[i]),
+			Precip:  floatOrEmpty(d.PrecipitationSum[i]),
+			UvIndex: roundOrEmpty(d.UvIndexMax[i]),
+		}
+		item.Wind360Day, item.WindDirDay, item.WindScaleDay, item.WindSpeedDay = windOrEmpty(d.WindSpeed10mMax[i], d.WindDirection10mDominant[i], lang)
+		// 日出日落格式为 2006-01-02T15:04, 只保留时间
+		if len(d.Sunrise[i]) >= 16 {
+			item.Sunrise = d.Sunrise[i][11:16]
+		}
+		if len(d.Sunset[i]) >= 16 {
+			item.Sunset = d.Sunset[i][11:16]
+		}
+		item.IconDay, item.TextDay = WMOIcon(d.WeatherCode[i], true, lang)
+		item.IconNight, item.TextNight = WMOIcon(d.WeatherCode[i], false, lang)
+		ret = append(ret, item)
+	}
+	return ret
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOpenMeteoGetWeather(t *testing.T) {
+	srv := newFixtureServer(t, map[string]string{"/forecast": "openmeteo/forecast_200.json"})
+	p := NewOpenMeteoProvider()
+	p.Host = srv.URL
+	w, err := p.GetWeather(context.Background(), "101010100")
+	if err != nil {
+		t.Fatalf("GetWeather() error = %v", err)
+	}
+	if w.Provider != ProviderOpenMeteo {
+		t.Errorf("Provider = %q, want %q", w.Provider, ProviderOpenMeteo)
+	}
+
+	// 实时天气:WMO 61为小雨,数值取整,能见度由米换算为公里
+	c := w.Current
+	if c.Icon != "305" || c.Text != "小雨" {
+		t.Errorf("Current = %s/%s, want 305/小雨", c.Icon, c.Text)
+	}
+	if c.Temp != "18" || c.FeelsLike != "16" || c.Vis != "10" || c.Precip != "0.3" || c.WindScale != "3" || c.WindDir != "东北风" {
+		t.Errorf("Current = %+v", c)
+	}
+	quantities := []struct {
+		name  string
+		got   Quantity
+		value float64
+		unit  Unit
+	}{
+		{"Temp", w.Now.Temp, 18, UnitCelsius},
+		{"WindSpeed", w.Now.WindSpeed, 14, UnitKmh},
+		{"Pressure", w.Now.Pressure, 1016, UnitHPa},
+		{"Vis", w.Now.Vis, 10, UnitKm},
+		{"Precip", w.Now.Precip, 0.3, UnitMm},
+		{"Dew", w.Now.Dew, 9, UnitCelsius},
+	}
+	for _, q := range quantities {
+		if !q.got.Valid || q.got.Value != q.value || q.got.Unit != q.unit {
+			t.Errorf("Now.%s = %+v, want %v%s", q.name, q.got, q.value, q.unit)
+		}
+	}
+
+	// 逐小时:雷阵雨,夜间晴使用夜间图标
+	hourly := []struct{ icon, text, pop string }{
+		{"305", "小雨", "70"},
+		{"302", "雷阵雨", "45"},
+		{"150", "晴", "5"},
+	}
+	if len(w.Hourly) != len(hourly) {
+		t.Fatalf("len(Hourly) = %d, want %d", len(w.Hourly), len(hourly))
+	}
+	for i, want := range hourly {
+		h := w.Hourly[i]
+		if h.Icon != want.icon || h.Text != want.text || h.Pop != want.pop {
+			t.Errorf("Hourly[%d] = %s/%s/%s, want %s/%s/%s", i, h.Icon, h.Text, h.Pop, want.icon, want.text, want.pop)
+		}
+	}
+
+	// 逐天:未知代码42为999,日出日落只保留时间
+	if len(w.Forecast) != 2 {
+		t.Fatalf("len(Forecast) = %d, want 2", len(w.Forecast))
+	}
+	d := w.Forecast[0]
+	if d.IconDay != "305" || d.TempMax != "19" || d.TempMin != "10" || d.Sunrise != "06:31" || d.Sunset != "17:31" || d.UvIndex != "3" {
+		t.Errorf("Forecast[0] = %+v", d)
+	}
+	if d := w.Forecast[1]; d.IconDay != "999" || d.TextDay != "未知" {
+		t.Errorf("Forecast[1] = %s/%s, want 999/未知", d.IconDay, d.TextDay)
+	}
+}
+
+func TestWMOIcon(t *testing.T) {
+	tests := []struct {
+		code  int
+		isDay bool
+		lang  string
+		icon  string
+		text  string
+	}{
+		{0, true, "", "100", "晴"},
+		{0, false, "", "150", "晴"},
+		{3, false, "", "104", "阴"},
+		{81, false, "", "350", "阵雨"},
+		{99, true, "", "304", "雷阵雨伴有冰雹"},
+		{61, true, "en", "305", "Light rain"},
+		{42, true, "", "999", "未知"},
+		{42, true, "en", "999", "Unknown"},
+	}
+	for _, tt := range tests {
+		icon, text := WMOIcon(tt.code, tt.isDay, tt.lang)
+		if icon != tt.icon || text != tt.text {
+			t.Errorf("WMOIcon(%d, %v, %q) = %s/%s, want %s/%s", tt.code, tt.isDay, tt.lang, icon, text, tt.icon, tt.text)
+		}
+	}
+}
+
+func TestOpenMeteoNullValues(t *testing.T) {
+	srv := newFixtureServer(t, map[string]string{"/forecast": "openmeteo/forecast_null.json"})
+	p := NewOpenMeteoProvider()
+	p.Host = srv.URL
+	w, err := p.GetWeather(context.Background(), "101010100")
+	if err != nil {
+		t.Fatalf("GetWeather() error = %v", err)
+	}
+	c := w.Current
+	if c.Vis != "" || c.WindSpeed != "" || c.WindScale != "" {
+		t.Errorf("Current Vis/WindSpeed/WindScale = %q/%q/%q, want empty", c.Vis, c.WindSpeed, c.WindScale)
+	}
+	// 风向不为null时照常解析
+	if c.WindDir != "东北风" || c.Temp != "18" {
+		t.Errorf("Current WindDir/Temp = %q/%q, want 东北风/18", c.WindDir, c.Temp)
+	}
+	if w.Now.Vis.Valid || w.Now.WindSpeed.Valid {
+		t.Errorf("Now Vis/WindSpeed = %+v/%+v, want invalid", w.Now.Vis, w.Now.WindSpeed)
+	}
+	pops := []string{"70", "", ""}
+	for i, want := range pops {
+		if got := w.Hourly[i].Pop; got != want {
+			t.Errorf("Hourly[%d].Pop = %q, want %q", i, got, want)
+		}
+	}
+	if w.Hourly[1].Dew != "" || w.Hourly[2].Dew != "8" {
+		t.Errorf("Hourly Dew = %q/%q, want empty/8", w.Hourly[1].Dew, w.Hourly[2].Dew)
+	}
+	if w.Forecast[0].UvIndex != "3" || w.Forecast[1].UvIndex != "" {
+		t.Errorf("Forecast UvIndex = %q/%q, want 3/empty", w.Forecast[0].UvIndex, w.Forecast[1].UvIndex)
+	}
+}
@@ -0,0 +1,387 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hw_weather_plugin/utils/utils"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenWeatherMap 接口地址
+const openWeatherHost = "https://api.openweathermap.org/data/2.5"
+
+// OpenWeatherMap 天气状况ID对应的和风天气图标与中文描述
+var owmConditions = map[int]struct {
+	Icon string
+	Text string
+}{
+	200: {"302", "雷阵雨"},
+	201: {"302", "雷阵雨"},
+	202: {"303", "强雷阵雨"},
+	210: {"302", "雷阵雨"},
+	211: {"302", "雷阵雨"},
+	212: {"303", "强雷阵雨"},
+	221: {"303", "强雷阵雨"},
+	230: {"302", "雷阵雨"},
+	231: {"302", "雷阵雨"},
+	232: {"302", "雷阵雨"},
+	300: {"309", "毛毛雨"},
+	301: {"309", "毛毛雨"},
+	302: {"309", "毛毛雨"},
+	310: {"309", "毛毛雨"},
+	311: {"309", "毛毛雨"},
+	312: {"309", "毛毛雨"},
+	313: {"300", "阵雨"},
+	314: {"301", "强阵雨"},
+	321: {"300", "阵雨"},
+	500: {"305", "小雨"},
+	501: {"306", "中雨"},
+	502: {"307", "大雨"},
+	503: {"310", "暴雨"},
+	504: {"311", "大暴雨"},
+	511: {"313", "冻雨"},
+	520: {"300", "阵雨"},
+	521: {"300", "阵雨"},
+	522: {"301", "强阵雨"},
+	531: {"300", "阵雨"},
+	600: {"400", "小雪"},
+	601: {"401", "中雪"},
+	602: {"402", "大雪"},
+	611: {"404", "雨夹雪"},
+	612: {"406", "阵雨夹雪"},
+	613: {"406", "阵雨夹雪"},
+	615: {"405", "雨雪天气"},
+	616: {"405", "雨雪天气"},
+	620: {"407", "阵雪"},
+	621: {"407", "阵雪"},
+	622: {"407", "阵雪"},
+	701: {"500", "薄雾"},
+	711: {"502", "霾"},
+	721: {"502", "霾"},
+	731: {"503", "扬沙"},
+	741: {"501", "雾"},
+	751: {"503", "扬沙"},
+	761: {"504", "浮尘"},
+	762: {"504", "火山灰"},
+	771: {"999", "狂风"},
+	781: {"999", "龙卷风"},
+	800: {"100", "晴"},
+	801: {"102", "少云"},
+	802: {"101", "多云"},
+	803: {"101", "多云"},
+	804: {"104", "阴"},
+}
+
+// OWMIcon 将 OpenWeatherMap 天气状况ID转换为和风天气图标代码与中文描述
+//
+// 其他语言的描述使用接口返回的 description
+func OWMIcon(id int, isDay bool) (icon, text string) {
+	v, ifSet := owmConditions[id]
+	if !ifSet {
+		return "999", "未知"
+	}
+	if !isDay {
+		return NightIcon(v.Icon), v.Text
+	}
+	return v.Icon, v.Text
+}
+
+type owmCondition struct {
+	ID          int    `json:"id"`
+	Main        string `json:"main"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+}
+
+type owmMain struct {
+	Temp      float64 `json:"temp"`
+	FeelsLike float64 `json:"feels_like"`
+	TempMin   float64 `json:"temp_min"`
+	TempMax   float64 `json:"temp_max"`
+	Pressure  float64 `json:"pressure"`
+	Humidity  float64 `json:"humidity"`
+}
+
+type owmWind struct {
+	Speed float64 `json:"speed"` // 风速,米/秒
+	Deg   float64 `json:"deg"`
+}
+
+type owmCurrentRaw struct {
+	Cod        any            `json:"cod"`
+	Message    string         `json:"message"`
+	Dt         int64          `json:"dt"`
+	Timezone   int            `json:"timezone"`
+	Name       string         `json:"name"`
+	Weather    []owmCondition `json:"weather"`
+	Main       owmMain        `json:"main"`
+	Visibility *float64       `json:"visibility"` // 单位米,无法获取时不返回
+	Wind       owmWind        `json:"wind"`
+	Clouds     struct {
+		All float64 `json:"all"`
+	} `json:"clouds"`
+	Rain struct {
+		OneHour float64 `json:"1h"`
+	} `json:"rain"`
+	Snow struct {
+		OneHour float64 `json:"1h"`
+	} `json:"snow"`
+	Sys struct {
+		Sunrise int64 `json:"sunrise"`
+		Sunset  int64 `json:"sunset"`
+	} `json:"sys"`
+}
+
+type owmForecastRaw struct {
+	Cod     any `json:"cod"`
+	Message any `json:"message"`
+	List    []struct {
+		Dt         int64          `json:"dt"`
+		Main       owmMain        `json:"main"`
+		Weather    []owmCondition `json:"weather"`
+		Visibility *float64       `json:"visibility"`
+		Wind       owmWind        `json:"wind"`
+		Pop        float64        `json:"pop"`
+		Clouds     struct {
+			All float64 `json:"all"`
+		} `json:"clouds"`
+		Rain struct {
+			ThreeHour float64 `json:"3h"`
+		} `json:"rain"`
+		Snow struct {
+			ThreeHour float64 `json:"3h"`
+		} `json:"snow"`
+		Sys struct {
+			Pod string `json:"pod"` // d为白天,n为夜间
+		} `json:"sys"`
+	} `json:"list"`
+	City struct {
+		Timezone int   `json:"timezone"`
+		Sunrise  int64 `json:"sunrise"`
+		Sunset   int64 `json:"sunset"`
+	} `json:"city"`
+}
+
+// OpenWeatherProvider OpenWeatherMap 数据源
+type OpenWeatherProvider struct {
+	Host string // 接口地址
+	Key  string // 秘钥
+	Lang string // 语言,中文使用内置描述,其他语言使用接口返回的描述
+}
+
+// owmLang 转换为 OpenWeatherMap 的语言代码
+func owmLang(lang string) string {
+	switch strings.ToLower(lang) {
+	case "", "zh", "zh-hans", "zh_cn":
+		return "zh_cn"
+	case "zh-hant", "zh_tw":
+		return "zh_tw"
+	}
+	return lang
+}
+
+// NewOpenWeatherProvider 创建 OpenWeatherMap 数据源
+func NewOpenWeatherProvider(key string) *OpenWeatherProvider {
+	return &OpenWeatherProvider{
+		Host: openWeatherHost,
+		Key:  key,
+		Lang: "zh",
+	}
+}
+
+func (p *OpenWeatherProvider) Name() string {
+	return ProviderOpenWeather
+}
+
+// locationQuery 生成位置查询参数
+//
+// cityID 可以是内置城市ID、"纬度,经度"或城市名称
+func (p *OpenWeatherProvider) locationQuery(cityID string) string {
+	if loc, err := GetLocation(cityID); err == nil {
+		return "lat=" + loc.Latitude + "&lon=" + loc.Longitude
+	}
+	if parts := strings.Split(cityID, ","); len(parts) == 2 {
+		_, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		_, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err1 == nil && err2 == nil {
+			return "lat=" + strings.TrimSpace(parts[0]) + "&lon=" + strings.TrimSpace(parts[1])
+		}
+	}
+	return "q=" + url.QueryEscape(cityID)
+}
+
+// conditionText 根据语言选择天气描述
+func (p *OpenWeatherProvider) conditionText(cond owmCondition, text string) string {
+	if IsChinese(p.Lang) || cond.Description == "" {
+		return text
+	}
+	return cond.Description
+}
+
+func (p *OpenWeatherProvider) get(ctx context.Context, path, cityID string, v any) error {
+	rawURL := fmt.Sprintf("%s/%s?%s&units=metric&lang=%s&appid=%s",
+		p.Host,
+		path,
+		p.locationQuery(cityID),
+		url.QueryEscape(owmLang(p.Lang)),
+		url.QueryEscape(p.Key),
+	)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	respData, status, err := doRequest(req)
+	if err != nil {
+		return err
+	}
+	// cod 可能为数字或字符串
+	code := struct {
+		Cod     any `json:"cod"`
+		Message any `json:"message"`
+	}{}
+	if err = json.Unmarshal(respData, &code); err != nil {
+		return newDecodeError(p.Name(), rawURL, status, err)
+	}
+	cod := ""
+	if code.Cod != nil {
+		cod = fmt.Sprint(code.Cod)
+	}
+	if err = newAPIError(p.Name(), rawURL, status, cod); err != nil {
+		if code.Message != nil {
+			err.(*APIError).Message = fmt.Sprint(code.Message)
+		}
+		return err
+	}
+	if err = json.Unmarshal(respData, v); err != nil {
+		return newDecodeError(p.Name(), rawURL, status, err)
+	}
+	return nil
+}
+
+func (p *OpenWeatherProvider) GetWeather(ctx context.Context, cityID string) (ret Weather, err error) {
+	if p.Key == "" {
+		return ret, errors.New("OpenWeatherMap秘钥不能为空")
+	}
+	current := owmCurrentRaw{}
+	if err = p.get(ctx, "weather", cityID, &current); err != nil {
+		return ret, err
+	}
+	forecast := owmForecastRaw{}
+	if err = p.get(ctx, "forecast", cityID, &forecast); err != nil {
+		return ret, err
+	}
+	ret.Provider = p.Name()
+	ret.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+	ret.Current = p.convertCurrent(&current)
+	ret.Hourly, ret.Forecast = p.convertForecast(&forecast)
+	ret.parse()
+	return ret, nil
+}
+
+func (p *OpenWeatherProvider) convertCurrent(raw *owmCurrentRaw) (ret WeatherStatus) {
+	zone := time.FixedZone("", raw.Timezone)
+	ret.ObsTime = time.Unix(raw.Dt, 0).In(zone).Format(qweatherTimeLayout)
+	ret.Temp = formatRound(raw.Main.Temp)
+	ret.FeelsLike = formatRound(raw.Main.FeelsLike)
+	ret.Icon, ret.Text = "999", "未知"
+	if len(raw.Weather) > 0 {
+		isDay := !strings.HasSuffix(raw.Weather[0].Icon, "n")
+		ret.Icon, ret.Text = OWMIcon(raw.Weather[0].ID, isDay)
+		ret.Text = p.conditionText(raw.Weather[0], ret.Text)
+	}
+	// 风速单位为米/秒
+	windSpeed := raw.Wind.Speed * 3.6
+	ret.Wind360 = formatRound(raw.Wind.Deg)
+	ret.WindDir = WindDir(raw.Wind.Deg, p.Lang)
+	ret.WindScale = strconv.Itoa(WindScale(windSpeed))
+	ret.WindSpeed = formatRound(windSpeed)
+	ret.Humidity = formatRound(raw.Main.Humidity)
+	ret.Precip = formatFloat(raw.Rain.OneHour + raw.Snow.OneHour)
+	ret.Pressure = formatRound(raw.Main.Pressure)
+	if raw.Visibility != nil {
+		ret.Vis = formatRound(*raw.Visibility / 1000)
+	}
+	ret.Cloud = formatRound(raw.Clouds.All)
+	return ret
+}
+
+// convertForecast 转换3小时间隔预报,逐天预报由同一天的数据汇总
+func (p *OpenWeatherProvider) convertForecast(raw *owmForecastRaw) (hourly []HourlyForecast, daily []DailyForecast) {
+	zone := time.FixedZone("", raw.City.Timezone)
+	dayIndex := make(map[string]int)
+	// 当天已选数据距离中午/午夜的小时数
+	dayDist := make(map[string]int)
+	nightDist := make(map[string]int)
+	for _, v := range raw.List {
+		t := time.Unix(v.Dt, 0).In(zone)
+		windSpeed := v.Wind.Speed * 3.6
+		item := HourlyForecast{
+			FxTime:    t.Format(qweatherTimeLayout),
+			Temp:      formatRound(v.Main.Temp),
+			Icon:      "999",
+			Text:      "未知",
+			Wind360:   formatRound(v.Wind.Deg),
+			WindDir:   WindDir(v.Wind.Deg, p.Lang),
+			WindScale: strconv.Itoa(WindScale(windSpeed)),
+			WindSpeed: formatRound(windSpeed),
+			Humidity:  formatRound(v.Main.Humidity),
+			Pop:       formatRound(v.Pop * 100),
+			Precip:    formatFloat(v.Rain.ThreeHour + v.Snow.ThreeHour),
+			Pressure:  formatRound(v.Main.Pressure),
+			Cloud:     formatRound(v.Clouds.All),
+		}
+		if len(v.Weather) > 0 {
+			item.Icon, item.Text = OWMIcon(v.Weather[0].ID, v.Sys.Pod != "n")
+			item.Text = p.conditionText(v.Weather[0], item.Text)
+		}
+		hourly = append(hourly, item)
+
+		date := t.Format("2006-01-02")
+		i, ifSet := dayIndex[date]
+		if !ifSet {
+			daily = append(daily, DailyForecast{
+				FxDate:  date,
+				TempMax: item.Temp,
+				TempMin: item.Temp,
+			})
+			i = len(daily) - 1
+			dayIndex[date] = i
+		}
+		day := &daily[i]
+		if v.Main.TempMax > parseFloat(day.TempMax) {
+			day.TempMax = formatRound(v.Main.TempMax)
+		}
+		if v.Main.TempMin < parseFloat(day.TempMin) {
+			day.TempMin = formatRound(v.Main.TempMin)
+		}
+		// 白天取最接近中午的数据,夜间取最接近午夜的数据
+		if d := utils.Abs(t.Hour() - 12); !ifSet || d < dayDist[date] {
+			dayDist[date] = d
+			day.IconDay, day.TextDay = DayIcon(item.Icon), item.Text
+			day.Wind360Day = item.Wind360
+			day.WindDirDay = item.WindDir
+			day.WindScaleDay = item.WindScale
+			day.WindSpeedDay = item.WindSpeed
+		}
+		if d := 12 - utils.Abs(t.Hour()-12); !ifSet || d < nightDist[date] {
+			nightDist[date] = d
+			day.IconNight, day.TextNight = NightIcon(item.Icon), item.Text
+			day.Wind360Night = item.Wind360
+			day.WindDirNight = item.WindDir
+			day.WindScaleNight = item.WindScale
+			day.WindSpeedNight = item.WindSpeed
+		}
+	}
+	return hourly, daily
+}
+
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOpenWeatherGetWeather(t *testing.T) {
+	SetResponseCacheTTL(0)
+	defer SetResponseCacheTTL(10 * time.Minute)
+	// 带特殊字符的秘钥与语言应原样到达接口
+	const (
+		key  = "k&units=imperial"
+		lang = "en#x"
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("appid") != key || q.Get("lang") != lang || q.Get("units") != "metric" {
+			t.Errorf("%s appid = %q, lang = %q, units = %q", r.URL.Path, q.Get("appid"), q.Get("lang"), q.Get("units"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/weather":
+			// 无法获取能见度时不返回 visibility
+			w.Write([]byte(`{"cod":200,"dt":1792382400,"timezone":28800,
+				"weather":[{"id":802,"description":"scattered clouds","icon":"03d"}],
+				"main":{"temp":18.2,"feels_like":17.5,"pressure":1016,"humidity":60},
+				"wind":{"speed":2.5,"deg":90},"clouds":{"all":40}}`))
+		case "/forecast":
+			w.Write([]byte(`{"cod":"200","list":[],"city":{"timezone":28800}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewOpenWeatherProvider(key)
+	p.Host, p.Lang = srv.URL, lang
+	w, err := p.GetWeather(context.Background(), "Paris")
+	if err != nil {
+		t.Fatalf("GetWeather() error = %v", err)
+	}
+	if w.Current.Temp != "18" || w.Current.Text != "scattered clouds" {
+		t.Errorf("Current = %s %s, want 18 scattered clouds", w.Current.Temp, w.Current.Text)
+	}
+	if w.Current.Vis != "" || w.Now.Vis.Valid {
+		t.Errorf("Vis = %q/%+v, want empty without visibility", w.Current.Vis, w.Now.Vis)
+	}
+}
@@ -0,0 +1,267 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Weather 与数据源无关的天气数据
+type Weather struct {
+	Provider   string                     `json:"provider"`    // 数据源名称
+	UpdateTime string                     `json:"update_time"` // 数据获取时间
+	Current    WeatherStatus              `json:"current"`     // 实时天气
+	Units      UnitSystem                 `json:"units"`       // 接口返回数据使用的单位,为空表示公制
+	Now        Observation                `json:"-"`           // 解析为数值的实时天气
+	Hourly     []HourlyForecast           `json:"hourly"`      // 逐小时预报,数据源不支持时为空
+	Forecast   []DailyForecast            `json:"forecast"`    // 逐天预报,数据源不支持时为空
+	Indexs     WeatherIndexs              `json:"indexs"`      // 生活指数,数据源不支持时为空
+	IndexDays  []IndexSet                 `json:"index_days"`  // 按日期排列的生活指数,第一项为当天,包含未知类型
+	Raw        map[string]json.RawMessage `json:"raw"`         // 接口原始数据,键为接口名称
+	Stale      bool                       `json:"-"`           // 是否为获取失败时使用的缓存数据
+	Missing    map[string]error           `json:"-"`           // 获取失败的部分,键为 Section 常量
+}
+
+type HourlyForecast struct {
+	FxTime    string `json:"fxTime"`    // 预报时间
+	Temp      string `json:"temp"`      // 温度
+	Icon      string `json:"icon"`      // 天气状况图标代码
+	Text      string `json:"text"`      // 天气状况文字描述
+	Wind360   string `json:"wind360"`   // 风向360角度
+	WindDir   string `json:"windDir"`   // 风向
+	WindScale string `json:"windScale"` // 风力等级
+	WindSpeed string `json:"windSpeed"` // 风速,公里/小时
+	Humidity  string `json:"humidity"`  // 相对湿度,百分比数值
+	Pop       string `json:"pop"`       // 降水概率,百分比数值,可能为空
+	Precip    string `json:"precip"`    // 当前小时累计降水量,默认单位:毫米
+	Pressure  string `json:"pressure"`  // 大气压强,默认单位:百帕
+	Cloud     string `json:"cloud"`     // 云量,百分比数值
+	Dew       string `json:"dew"`       // 露点温度
+}
+
+type DailyForecast struct {
+	FxDate         string `json:"fxDate"`         // 预报日期
+	Sunrise        string `json:"sunrise"`        // 日出时间
+	Sunset         string `json:"sunset"`         // 日落时间
+	TempMax        string `json:"tempMax"`        // 最高温度
+	TempMin        string `json:"tempMin"`        // 最低温度
+	IconDay        string `json:"iconDay"`        // 白天天气状况图标代码
+	TextDay        string `json:"textDay"`        // 白天天气状况文字描述
+	IconNight      string `json:"iconNight"`      // 夜间天气状况图标代码
+	TextNight      string `json:"textNight"`      // 夜间天气状况文字描述
+	Wind360Day     string `json:"wind360Day"`     // 白天风向360角度
+	WindDirDay     string `json:"windDirDay"`     // 白天风向
+	WindScaleDay   string `json:"windScaleDay"`   // 白天风力等级
+	WindSpeedDay   string `json:"windSpeedDay"`   // 白天风速,公里/小时
+	Wind360Night   string `json:"wind360Night"`   // 夜间风向360角度
+	WindDirNight   string `json:"windDirNight"`   // 夜间风向
+	WindScaleNight string `json:"windScaleNight"` // 夜间风力等级
+	WindSpeedNight string `json:"windSpeedNight"` // 夜间风速,公里/小时
+	Humidity       string `json:"humidity"`       // 相对湿度,百分比数值
+	Precip         string `json:"precip"`         // 当天总降水量,默认单位:毫米
+	Pressure       string `json:"pressure"`       // 大气压强,默认单位:百帕
+	Vis            string `json:"vis"`            // 能见度,默认单位:公里
+	Cloud          string `json:"cloud"`          // 云量,百分比数值
+	UvIndex        string `json:"uvIndex"`        // 紫外线强度指数
+}
+
+// IndicesOn 获取第day天的生活指数,0为当天,没有数据时返回nil
+func (w *Weather) IndicesOn(day int) IndexSet {
+	if day < len(w.IndexDays) {
+		return w.IndexDays[day]
+	}
+	if day == 0 {
+		return NewIndexSet(w.Indexs)
+	}
+	return nil
+}
+
+// parse 解析实时天气,数据源返回数据前调用
+func (w *Weather) parse() {
+	w.Now = ParseObservation(w.Current, w.Units)
+}
+
+// WeatherProvider 天气数据源
+type WeatherProvider interface {
+	// Name 数据源名称
+	Name() string
+	// GetWeather 获取城市天气,ctx 取消或到达截止时间时停止未完成的请求
+	GetWeather(ctx context.Context, cityID string) (Weather, error)
+}
+
+// Capability 查找数据源支持的能力,例如 AstronomyProvider、TideProvider
+//
+// 缓存、次数保护等包装数据源通过 Unwrap() WeatherProvider 返回被包装的数据源;
+// 自动切换等组合数据源通过 Unwrap() []WeatherProvider 返回全部数据源,
+// 只有至少一个数据源支持时才算支持,此时使用组合数据源自身的实现
+func Capability[T any](p WeatherProvider) (ret T, ok bool) {
+	switch w := p.(type) {
+	case interface{ Unwrap() []WeatherProvider }:
+		for _, v := range w.Unwrap() {
+			if ret, ok = Capability[T](v); ok {
+				break
+			}
+		}
+		if !ok {
+			return ret, false
+		}
+		if v, isT := p.(T); isT {
+			return v, true
+		}
+		return ret, true
+	case interface{ Unwrap() WeatherProvider }:
+		if v, isT := p.(T); isT {
+			return v, true
+		}
+		return Capability[T](w.Unwrap())
+	}
+	ret, ok = p.(T)
+	return ret, ok
+}
+
+// AstronomyProvider 支持天文数据的数据源
+type AstronomyProvider interface {
+	GetAstronomy(ctx context.Context, cityID string, date time.Time) (Astronomy, error)
+}
+
+const (
+	// ProviderShared 共享接口
+	ProviderShared = "shared"
+	// ProviderQWeather 和风天气
+	ProviderQWeather = "qweather"
+	// ProviderOpenMeteo Open-Meteo
+	ProviderOpenMeteo = "openmeteo"
+	// ProviderOpenWeather OpenWeatherMap
+	ProviderOpenWeather = "openweather"
+)
+
+// SharedProvider 共享接口数据源
+type SharedProvider struct {
+	Endpoint string // 共享接口地址,为空使用 SetSharedEndpoint 设置的地址
+}
+
+// NewSharedProvider 创建共享接口数据源
+func NewSharedProvider() *SharedProvider {
+	return &SharedProvider{}
+}
+
+func (p *SharedProvider) Name() string {
+	return ProviderShared
+}
+
+func (p *SharedProvider) GetWeather(ctx context.Context, cityID string) (ret Weather, err error) {
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = getSharedEndpoint()
+	}
+	resp, err := getWeatherFrom(ctx, endpoint, cityID)
+	if err != nil {
+		return ret, err
+	}
+	ret.Provider = p.Name()
+	ret.UpdateTime = resp.UpdateTime
+	ret.Current = resp.WeatherStatus
+	ret.Indexs = resp.WeatherIndexs
+	ret.parse()
+	return ret, nil
+}
+
+// QWeatherProvider 和风天气数据源
+type QWeatherProvider struct {
+	Host   string       // 接口地址
+	Key    string       // 秘钥
+	Signer *TokenSigner // JWT签名器,设置后使用JWT认证代替秘钥
+
+	IndexDays int           // 生活指数天数,支持1天与3天,为0时获取1天
+	Timeout   time.Duration // 实时天气与生活指数共享的超时时间,调用方的截止时间更早时以其为准
+	Units     UnitSystem    // 显示单位,英制时请求接口的英制数据
+	Lang      string        // 天气描述、风向与指数建议的语言
+}
+
+// NewQWeatherProvider 创建和风天气数据源
+func NewQWeatherProvider(host, key string) *QWeatherProvider {
+	return &QWeatherProvider{
+		Host:    host,
+		Key:     key,
+		Timeout: 10 * time.Second,
+	}
+}
+
+func (p *QWeatherProvider) Name() string {
+	return ProviderQWeather
+}
+
+func (p *QWeatherProvider) GetWeather(ctx context.Context, cityID string) (ret Weather, err error) {
+	if p.Key == "" && p.Signer == nil {
+		return ret, errors.New("和风天气秘钥不能为空")
+	}
+	ret.Provider = p.Name()
+	ret.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+	ret.Raw = make(map[string]json.RawMessage)
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	opt := p.options(ctx)
+	if opt.Unit == "i" {
+		ret.Units = qweatherImperialUnits
+	}
+	indexDays := p.IndexDays
+	if indexDays <= 0 {
+		indexDays = 1
+	}
+	tasks := []FetchTask{
+		{Name: SectionNow, Fetch: func() (func(), error) {
+			r, raw, err := GetCurrentWeather(cityID, p.Host, p.Key, opt)
+			return func() {
+				ret.Current = r.Now
+				ret.Raw[SectionNow] = raw
+			}, err
+		}},
+		{Name: SectionIndices, Fetch: func() (func(), error) {
+			r, raw, err := GetWeatherIndexDays(cityID, p.Host, p.Key, indexDays, opt)
+			return func() {
+				ret.Indexs = r.Index
+				ret.IndexDays = r.Days
+				ret.Raw[SectionIndices] = raw
+			}, err
+		}},
+	}
+	errs := FetchAll(ctx, tasks...)
+	// 实时天气是必需的,其他部分失败时标记缺失
+	if err, ifSet := errs[SectionNow]; ifSet {
+		return ret, err
+	}
+	if len(errs) > 0 {
+		ret.Missing = errs
+	}
+	ret.parse()
+	return ret, nil
+}
+
+func (p *QWeatherProvider) GetAstronomy(ctx context.Context, cityID string, date time.Time) (Astronomy, error) {
+	return GetAstronomy(cityID, p.Host, p.Key, date, p.options(ctx))
+}
+
+func (p *QWeatherProvider) GetHistorical(ctx context.Context, cityID string, date time.Time) (DaySummary, error) {
+	return GetHistoricalWeather(cityID, p.Host, p.Key, date, p.options(ctx))
+}
+
+func (p *QWeatherProvider) GetActiveStorms(ctx context.Context, basin string) ([]StormTrack, error) {
+	return GetActiveStorms(p.Host, p.Key, basin, time.Now(), p.options(ctx))
+}
+
+func (p *QWeatherProvider) GetTide(ctx context.Context, poiID string, date time.Time) (TideTable, error) {
+	return GetTide(poiID, p.Host, p.Key, date, p.options(ctx))
+}
+
+// options 请求参数
+func (p *QWeatherProvider) options(ctx context.Context) RequestOptions {
+	opt := RequestOptions{Lang: p.Lang, Signer: p.Signer, Context: ctx}
+	if p.Units != (UnitSystem{}) {
+		opt.Unit = p.Units.QWeatherUnit()
+	}
+	return opt
+}
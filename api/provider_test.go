@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newFixtureServer 启动本地接口服务,按请求路径返回 testdata 下的响应样本
+//
+// routes: 键为请求路径,值为相对 testdata 的文件名,键为"*"时匹配其他所有路径
+func newFixtureServer(t *testing.T, routes map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ifSet := routes[r.URL.Path]
+		if !ifSet {
+			name, ifSet = routes["*"]
+		}
+		if !ifSet {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Errorf("读取样本%s失败:%v", name, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProviderGetWeather(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider func(host string) WeatherProvider
+		routes   map[string]string
+		dress    string // 穿衣指数的等级描述
+		indices  int    // 当天生活指数的数量
+	}{
+		{
+			name: ProviderShared,
+			provider: func(host string) WeatherProvider {
+				SetSharedEndpoint(host + "/weather")
+				return NewSharedProvider()
+			},
+			routes:  map[string]string{"/weather": "shared/weather_200.json"},
+			dress:   "较舒适",
+			indices: 2,
+		},
+		{
+			name: ProviderQWeather,
+			provider: func(host string) WeatherProvider {
+				return NewQWeatherProvider(host, "test")
+			},
+			routes: map[string]string{
+				"/weather/now": "qweather/now_200.json",
+				"/indices/1d":  "qweather/indices_1d_200.json",
+			},
+			dress:   "较舒适",
+			indices: 5,
+		},
+	}
+	defer SetSharedEndpoint("")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFixtureServer(t, tt.routes)
+			w, err := tt.provider(srv.URL).GetWeather(context.Background(), "101010100")
+			if err != nil {
+				t.Fatalf("GetWeather() error = %v", err)
+			}
+			if w.Provider != tt.name {
+				t.Errorf("Provider = %q, want %q", w.Provider, tt.name)
+			}
+			if len(w.Missing) > 0 {
+				t.Errorf("Missing = %v, want empty", w.Missing)
+			}
+			if w.Current.Text != "多云" || w.Current.Icon != "101" {
+				t.Errorf("Current = %s/%s, want 多云/101", w.Current.Text, w.Current.Icon)
+			}
+			now := w.Now
+			if !now.Temp.Valid || now.Temp.Value != 18 || now.Temp.Unit != UnitCelsius {
+				t.Errorf("Now.Temp = %+v, want 18°C", now.Temp)
+			}
+			if !now.WindSpeed.Valid || now.WindSpeed.Value != 9 || now.WindSpeed.Unit != UnitKmh {
+				t.Errorf("Now.WindSpeed = %+v, want 9km/h", now.WindSpeed)
+			}
+			if !now.Pressure.Valid || now.Pressure.Value != 1016 {
+				t.Errorf("Now.Pressure = %+v, want 1016", now.Pressure)
+			}
+			if now.ObsTime.IsZero() {
+				t.Error("Now.ObsTime is zero")
+			}
+			if w.Indexs.Dress.Category != tt.dress {
+				t.Errorf("Indexs.Dress.Category = %q, want %q", w.Indexs.Dress.Category, tt.dress)
+			}
+			if n := len(w.IndicesOn(0)); n != tt.indices {
+				t.Errorf("len(IndicesOn(0)) = %d, want %d", n, tt.indices)
+			}
+		})
+	}
+}
+
+func TestQWeatherRequestsEscapeQuery(t *testing.T) {
+	SetResponseCacheTTL(0)
+	defer SetResponseCacheTTL(10 * time.Minute)
+	fixtures := newFixtureServer(t, map[string]string{
+		"/weather/now":    "qweather/now_200.json",
+		"/indices/1d":     "qweather/indices_1d_200.json",
+		"/astronomy/sun":  "qweather/sun_200.json",
+		"/astronomy/moon": "qweather/moon_200.json",
+	})
+	var (
+		lock      sync.Mutex
+		langs     = make(map[string]string)
+		locations = make(map[string]string)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		langs[r.URL.Path] = r.URL.Query().Get("lang")
+		locations[r.URL.Path] = r.URL.Query().Get("location")
+		lock.Unlock()
+		fixtures.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	// 带特殊字符的城市ID与语言参数应原样到达接口,不会拆分出其他参数
+	const (
+		cityID = "101010100&lang=zh#x"
+		lang   = "en&unit=i"
+	)
+	p := NewQWeatherProvider(srv.URL, "test")
+	p.Lang = lang
+	if _, err := p.GetWeather(context.Background(), cityID); err != nil {
+		t.Fatalf("GetWeather() error = %v", err)
+	}
+	date := time.Date(2026, 10, 19, 12, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	a, err := p.GetAstronomy(context.Background(), cityID, date)
+	if err != nil {
+		t.Fatalf("GetAstronomy() error = %v", err)
+	}
+	if a.MoonPhase.Name != "Waxing Crescent" || a.Sunrise.Format("15:04") != "06:31" {
+		t.Errorf("GetAstronomy() = %s %s, want Waxing Crescent 06:31", a.MoonPhase.Name, a.Sunrise.Format("15:04"))
+	}
+	for _, path := range []string{"/weather/now", "/indices/1d", "/astronomy/sun", "/astronomy/moon"} {
+		if got, ifSet := langs[path]; !ifSet || got != lang {
+			t.Errorf("%s lang = %q, want %q", path, got, lang)
+		}
+		if got := locations[path]; got != cityID {
+			t.Errorf("%s location = %q, want %q", path, got, cityID)
+		}
+	}
+}
+
+func TestCapability(t *testing.T) {
+	qweather := NewQWeatherProvider("https://devapi.qweather.com/v7", "test")
+	shared := NewSharedProvider()
+	chain := NewFailoverProvider(shared, NewQuotaGuardProvider(qweather, "test", 0))
+	tests := []struct {
+		name     string
+		provider WeatherProvider
+		want     WeatherProvider // 为nil时应不支持
+	}{
+		{"shared", NewCacheProvider(shared, ""), nil},
+		{"qweather", NewCacheProvider(NewQuotaGuardProvider(qweather, "test", 0), ""), qweather},
+		{"failover without qweather", NewCacheProvider(NewFailoverProvider(shared, NewOpenMeteoProvider()), ""), nil},
+		{"failover with qweather", NewCacheProvider(chain, ""), chain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tide, ok := Capability[TideProvider](tt.provider)
+			if ok != (tt.want != nil) {
+				t.Fatalf("Capability[TideProvider]() ok = %v, want %v", ok, tt.want != nil)
+			}
+			if ok && tide.(WeatherProvider) != tt.want {
+				t.Errorf("Capability[TideProvider]() = %T, want %T", tide, tt.want)
+			}
+			if _, ok := Capability[StormProvider](tt.provider); ok != (tt.want != nil) {
+				t.Errorf("Capability[StormProvider]() ok = %v, want %v", ok, tt.want != nil)
+			}
+		})
+	}
+}
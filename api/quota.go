@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded 当日接口次数已用完
+var ErrQuotaExceeded = errors.New("今日接口次数已达上限")
+
+// 保留的统计天数
+const quotaKeepDays = 7
+
+var (
+	quotaLock sync.Mutex
+	quotaFile string
+	// 每日请求次数,键为日期,值为秘钥摘要到次数的映射
+	quotaCounts = make(map[string]map[string]int)
+	// 每日次数上限,键为秘钥摘要,由 QuotaGuardProvider 在请求前设置
+	quotaLimits = make(map[string]int)
+	// 最近一次写入统计文件的错误,由 QuotaGuardProvider 输出到日志
+	quotaSaveErr error
+)
+
+// quotaKeyID 计算秘钥摘要,避免在统计文件中保存明文秘钥
+func quotaKeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// requestKey 获取请求使用的秘钥
+func requestKey(req *http.Request) string {
+	// JWT认证时以凭据ID区分账号
+	if kid := tokenKeyID(req.Header.Get("Authorization")); kid != "" {
+		return kid
+	}
+	query := req.URL.Query()
+	if key := query.Get("key"); key != "" {
+		return key
+	}
+	return query.Get("appid")
+}
+
+// SetQuotaFile 设置请求次数统计文件并载入已有数据
+func SetQuotaFile(path string) error {
+	quotaLock.Lock()
+	defer quotaLock.Unlock()
+	if quotaFile == path {
+		return nil
+	}
+	quotaFile = path
+	quotaCounts = make(map[string]map[string]int)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(data, &quotaCounts)
+}
+
+// SetQuotaLimit 设置秘钥的每日次数上限,为0时不限制
+//
+// QuotaGuardProvider 每次获取前会按自身配置设置,移除保护时需手动设为0
+func SetQuotaLimit(key string, limit int) {
+	quotaLock.Lock()
+	defer quotaLock.Unlock()
+	if limit <= 0 {
+		delete(quotaLimits, quotaKeyID(key))
+		return
+	}
+	quotaLimits[quotaKeyID(key)] = limit
+}
+
+// takeQuota 记录一次使用秘钥的请求
+//
+// 在发出请求前检查上限,一次获取包含多个请求时也不会超出上限,
+// 已达上限时不记录并返回 ErrQuotaExceeded
+func takeQuota(key string) error {
+	if key == "" {
+		return nil
+	}
+	quotaLock.Lock()
+	defer quotaLock.Unlock()
+	id := quotaKeyID(key)
+	today := time.Now().Format("2006-01-02")
+	if limit, ifSet := quotaLimits[id]; ifSet && quotaCounts[today][id] >= limit {
+		return ErrQuotaExceeded
+	}
+	if quotaCounts[today] == nil {
+		quotaCounts[today] = make(map[string]int)
+	}
+	quotaCounts[today][id]++
+	// 清理过期的统计
+	if len(quotaCounts) > quotaKeepDays {
+		days := make([]string, 0, len(quotaCounts))
+		for day := range quotaCounts {
+			days = append(days, day)
+		}
+		sort.Strings(days)
+		for _, day := range days[:len(days)-quotaKeepDays] {
+			delete(quotaCounts, day)
+		}
+	}
+	if quotaFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(quotaCounts)
+	if err == nil {
+		err = os.WriteFile(quotaFile, data, 0644)
+	}
+	if err != nil {
+		quotaSaveErr = err
+	}
+	return nil
+}
+
+// takeQuotaSaveError 取出最近一次写入统计文件的错误
+func takeQuotaSaveError() error {
+	quotaLock.Lock()
+	defer quotaLock.Unlock()
+	err := quotaSaveErr
+	quotaSaveErr = nil
+	return err
+}
+
+// QuotaUsed 获取秘钥今日已使用的请求次数
+func QuotaUsed(key string) int {
+	quotaLock.Lock()
+	defer quotaLock.Unlock()
+	return quotaCounts[time.Now().Format("2006-01-02")][quotaKeyID(key)]
+}
+
+// QuotaGuardProvider 接口次数保护
+//
+// 当日请求次数达到上限后不再请求原数据源,改用备用数据源,没有备用数据源时返回 ErrQuotaExceeded;
+// 上限同时在发出每个请求前检查,获取过程中用完时剩余的请求直接返回 ErrQuotaExceeded
+type QuotaGuardProvider struct {
+	Provider WeatherProvider
+	Fallback WeatherProvider  // 备用数据源,可为空
+	Key      string           // 需要统计的秘钥
+	Limit    int              // 每日次数上限,为0时不限制
+	Warn     float64          // 用量达到上限的该比例时输出警告
+	Log      func(msg string) // 日志输出,可为空
+}
+
+// NewQuotaGuardProvider 创建接口次数保护
+func NewQuotaGuardProvider(provider WeatherProvider, key string, limit int) *QuotaGuardProvider {
+	SetQuotaLimit(key, limit)
+	return &QuotaGuardProvider{
+		Provider: provider,
+		Key:      key,
+		Limit:    limit,
+		Warn:     0.8,
+	}
+}
+
+func (p *QuotaGuardProvider) Name() string {
+	return p.Provider.Name()
+}
+
+func (p *QuotaGuardProvider) log(format string, args ...any) {
+	if p.Log != nil {
+		p.Log(fmt.Sprintf(format, args...))
+	}
+}
+
+func (p *QuotaGuardProvider) exceeded() bool {
+	SetQuotaLimit(p.Key, p.Limit)
+	return p.Limit > 0 && QuotaUsed(p.Key) >= p.Limit
+}
+
+func (p *QuotaGuardProvider) GetWeather(ctx context.Context, cityID string) (ret Weather, err error) {
+	if p.exceeded() {
+		if p.Fallback == nil {
+			p.log("%s今日接口次数已达上限%d次,使用缓存数据", p.Provider.Name(), p.Limit)
+			return ret, ErrQuotaExceeded
+		}
+		p.log("%s今日接口次数已达上限%d次,改用%s", p.Provider.Name(), p.Limit, p.Fallback.Name())
+		return p.Fallback.GetWeather(ctx, cityID)
+	}
+	ret, err = p.Provider.GetWeather(ctx, cityID)
+	if saveErr := takeQuotaSaveError(); saveErr != nil {
+		p.log("请求次数统计写入失败:%v", saveErr)
+	}
+	if errors.Is(err, ErrQuotaExceeded) && p.Fallback != nil {
+		p.log("%s今日接口次数已达上限%d次,改用%s", p.Provider.Name(), p.Limit, p.Fallback.Name())
+		return p.Fallback.GetWeather(ctx, cityID)
+	}
+	used := QuotaUsed(p.Key)
+	p.log("%s今日已请求%d次", p.Provider.Name(), used)
+	if p.Limit > 0 && float64(used) >= float64(p.Limit)*p.Warn {
+		p.log("%s今日接口次数即将用完:%d/%d", p.Provider.Name(), used, p.Limit)
+	}
+	return ret, err
+}
+
+// Unwrap 被保护的数据源,天文、历史天气等能力通过 Capability 查找,次数上限由请求前的检查保证
+func (p *QuotaGuardProvider) Unwrap() WeatherProvider {
+	return p.Provider
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQuotaGuardStopsWithinFetch(t *testing.T) {
+	SetResponseCacheTTL(0)
+	defer SetResponseCacheTTL(10 * time.Minute)
+	fixtures := newFixtureServer(t, map[string]string{
+		"/weather/now": "qweather/now_200.json",
+		"/indices/1d":  "qweather/indices_1d_200.json",
+	})
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		fixtures.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	// 一次获取包含实时天气与生活指数两个请求,上限1次时只能发出其中一个
+	key := "quota-test-" + time.Now().Format(time.RFC3339Nano)
+	guard := NewQuotaGuardProvider(NewQWeatherProvider(srv.URL, key), key, 1)
+	defer SetQuotaLimit(key, 0)
+	w, err := guard.GetWeather(context.Background(), "101010100")
+	if err == nil {
+		err = w.Missing[SectionIndices]
+	}
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("GetWeather() error = %v, Missing = %v, want %v", err, w.Missing, ErrQuotaExceeded)
+	}
+	if _, err := guard.GetWeather(context.Background(), "101010100"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("second GetWeather() error = %v, want %v", err, ErrQuotaExceeded)
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("server hits = %d, want 1", n)
+	}
+	if used := QuotaUsed(key); used != 1 {
+		t.Errorf("QuotaUsed() = %d, want 1", used)
+	}
+	if IsRetryable(err) {
+		t.Error("quota error is retryable")
+	}
+}
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimiter 令牌桶限流器
+type RateLimiter struct {
+	lock     sync.Mutex
+	rate     float64 // 每秒生成的令牌数
+	capacity float64 // 令牌桶容量
+	tokens   float64
+	last     time.Time
+}
+
+// NewRateLimiter 创建令牌桶限流器
+//
+// rate: 每秒生成的令牌数
+// capacity: 令牌桶容量
+func NewRateLimiter(rate float64, capacity int) *RateLimiter {
+	return &RateLimiter{
+		rate:     rate,
+		capacity: float64(capacity),
+		tokens:   float64(capacity),
+		last:     time.Now(),
+	}
+}
+
+// reserve 取出一个令牌,返回需要等待的时间
+func (l *RateLimiter) reserve() time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.rate <= 0 {
+		return 0
+	}
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.capacity {
+		l.tokens = l.capacity
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// Allow 尝试取出一个令牌,没有可用令牌时立即返回false
+func (l *RateLimiter) Allow() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.rate <= 0 {
+		return true
+	}
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.capacity {
+		l.tokens = l.capacity
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+func (l *RateLimiter) same(rate float64, capacity int) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.rate == rate && l.capacity == float64(capacity)
+}
+
+// cancel 归还 reserve 取出但未使用的令牌
+func (l *RateLimiter) cancel() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.rate <= 0 {
+		return
+	}
+	l.tokens++
+	if l.tokens > l.capacity {
+		l.tokens = l.capacity
+	}
+}
+
+// Wait 阻塞直到获取到令牌或 ctx 结束
+//
+// ctx 结束时归还令牌并返回 ctx 的错误
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	d := l.reserve()
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	}
+}
+
+var (
+	rateLimitLock sync.Mutex
+	// 所有接口共用的限流器,每秒10个令牌,令牌桶容量30个
+	defaultLimiter = NewRateLimiter(10, 30)
+	// 单独配置的限流器,键为接口域名
+	hostLimiters = make(map[string]*RateLimiter)
+)
+
+// SetRateLimit 设置接口域名的限流
+//
+// host为空时设置共用限流器, rate为0时移除该域名的单独配置
+// 配置未变化时保留原限流器,不会重置已消耗的令牌
+func SetRateLimit(host string, rate float64, capacity int) {
+	rateLimitLock.Lock()
+	defer rateLimitLock.Unlock()
+	if host == "" {
+		if !defaultLimiter.same(rate, capacity) {
+			defaultLimiter = NewRateLimiter(rate, capacity)
+		}
+		return
+	}
+	if rate <= 0 {
+		delete(hostLimiters, host)
+		return
+	}
+	if l, ifSet := hostLimiters[host]; !ifSet || !l.same(rate, capacity) {
+		hostLimiters[host] = NewRateLimiter(rate, capacity)
+	}
+}
+
+func limiterFor(host string) *RateLimiter {
+	rateLimitLock.Lock()
+	defer rateLimitLock.Unlock()
+	if l, ifSet := hostLimiters[host]; ifSet {
+		return l
+	}
+	return defaultLimiter
+}
+
+// limitTransport 为所有请求加上响应缓存与限流
+type limitTransport struct {
+	base http.RoundTripper
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, err := responseCacheKey(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp, ok := getCachedResponse(key, req); ok {
+		return resp, nil
+	}
+	if err := limiterFor(req.URL.Hostname()).Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	if err := takeQuota(requestKey(req)); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	return cacheResponse(key, resp)
+}
+
+// 数据源使用的接口域名
+var providerHosts = map[string][]string{
+	ProviderShared:      {"openapi.hyiy.top"},
+	ProviderQWeather:    {"api.qweather.com", "devapi.qweather.com"},
+	ProviderOpenMeteo:   {"api.open-meteo.com"},
+	ProviderOpenWeather: {"api.openweathermap.org"},
+}
+
+// RegisterProviderHost 登记数据源使用的自定义接口域名,使数据源限流同样作用于该域名
+func RegisterProviderHost(provider, host string) {
+	rateLimitLock.Lock()
+	defer rateLimitLock.Unlock()
+	for _, v := range providerHosts[provider] {
+		if v == host {
+			return
+		}
+	}
+	providerHosts[provider] = append(providerHosts[provider], host)
+}
+
+// SetProviderRateLimit 设置数据源的限流
+func SetProviderRateLimit(provider string, rate float64, capacity int) error {
+	rateLimitLock.Lock()
+	hosts, ifSet := providerHosts[provider]
+	hosts = append([]string(nil), hosts...)
+	rateLimitLock.Unlock()
+	if !ifSet {
+		return fmt.Errorf("未知数据源:%s", provider)
+	}
+	for _, host := range hosts {
+		SetRateLimit(host, rate, capacity)
+	}
+	return nil
+}
+
+// RateLimit 数据源的限流配置
+type RateLimit struct {
+	Rate     float64 // 每秒生成的令牌数,为0时不单独限流
+	Capacity int     // 令牌桶容量
+}
+
+// SetProviderRateLimits 按数据源重建全部单独配置的限流器
+//
+// 不在 limits 中的域名恢复使用共用限流器,配置未变化的限流器保留已消耗的令牌
+func SetProviderRateLimits(limits map[string]RateLimit) error {
+	rateLimitLock.Lock()
+	defer rateLimitLock.Unlock()
+	limiters := make(map[string]*RateLimiter)
+	for provider, limit := range limits {
+		hosts, ifSet := providerHosts[provider]
+		if !ifSet {
+			return fmt.Errorf("未知数据源:%s", provider)
+		}
+		if limit.Rate <= 0 {
+			continue
+		}
+		for _, host := range hosts {
+			if l, ifSet := hostLimiters[host]; ifSet && l.same(limit.Rate, limit.Capacity) {
+				limiters[host] = l
+			} else {
+				limiters[host] = NewRateLimiter(limit.Rate, limit.Capacity)
+			}
+		}
+	}
+	hostLimiters = limiters
+	return nil
+}
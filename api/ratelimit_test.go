@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWaitContext(t *testing.T) {
+	l := NewRateLimiter(0.1, 1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	// 令牌已用完,下一个令牌需要10秒,应随 ctx 提前返回
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Wait() returned after %v", d)
+	}
+	// 取消的等待归还令牌,不会让后续请求等待更久
+	l.lock.Lock()
+	tokens := l.tokens
+	l.lock.Unlock()
+	if tokens > 0.01 || tokens < -0.01 {
+		t.Errorf("tokens = %v after cancelled Wait, want about 0", tokens)
+	}
+}
+
+func TestSetProviderRateLimits(t *testing.T) {
+	defer SetProviderRateLimits(nil)
+	if err := SetProviderRateLimits(map[string]RateLimit{
+		ProviderOpenMeteo: {Rate: 1, Capacity: 5},
+		ProviderQWeather:  {Rate: 2, Capacity: 10},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	meteo := limiterFor("api.open-meteo.com")
+	if meteo == defaultLimiter || !meteo.same(1, 5) {
+		t.Fatal("open-meteo limiter not set")
+	}
+	// 删除 qweather 的配置后恢复共用限流器,未变化的 open-meteo 保留原限流器
+	if err := SetProviderRateLimits(map[string]RateLimit{ProviderOpenMeteo: {Rate: 1, Capacity: 5}}); err != nil {
+		t.Fatal(err)
+	}
+	if l := limiterFor("api.qweather.com"); l != defaultLimiter {
+		t.Error("qweather limiter still active after removal")
+	}
+	if l := limiterFor("api.open-meteo.com"); l != meteo {
+		t.Error("unchanged open-meteo limiter was replaced")
+	}
+	if err := SetProviderRateLimits(map[string]RateLimit{"unknown": {Rate: 1, Capacity: 1}}); err == nil {
+		t.Error("unknown provider accepted")
+	}
+}
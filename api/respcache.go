@@ -0,0 +1,137 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type cachedResponse struct {
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+var (
+	responseCacheLock sync.Mutex
+	// 接口响应缓存,键为请求方法、地址与请求体
+	responseCache = make(map[string]cachedResponse)
+	// 响应缓存有效期,短时间内重复手动更新时复用数据,节省接口次数
+	responseCacheTTL = 10 * time.Minute
+)
+
+// SetResponseCacheTTL 设置响应缓存有效期,为0时关闭缓存
+func SetResponseCacheTTL(ttl time.Duration) {
+	responseCacheLock.Lock()
+	defer responseCacheLock.Unlock()
+	responseCacheTTL = ttl
+	if ttl <= 0 {
+		responseCache = make(map[string]cachedResponse)
+	}
+}
+
+// ClearResponseCache 清空响应缓存
+func ClearResponseCache() {
+	responseCacheLock.Lock()
+	defer responseCacheLock.Unlock()
+	responseCache = make(map[string]cachedResponse)
+}
+
+func responseCacheKey(req *http.Request) (string, error) {
+	key := req.Method + " " + req.URL.String()
+	if req.Body == nil || req.Body == http.NoBody {
+		return key, nil
+	}
+	// 读取请求体作为键的一部分,然后放回请求
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return key + " " + string(body), nil
+}
+
+func getCachedResponse(key string, req *http.Request) (*http.Response, bool) {
+	responseCacheLock.Lock()
+	defer responseCacheLock.Unlock()
+	c, ifSet := responseCache[key]
+	if !ifSet {
+		return nil, false
+	}
+	if time.Now().After(c.expires) {
+		delete(responseCache, key)
+		return nil, false
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(c.body)),
+		ContentLength: int64(len(c.body)),
+		Request:       req,
+	}, true
+}
+
+// cacheResponse 缓存成功的响应,返回可以继续读取的响应
+func cacheResponse(key string, resp *http.Response) (*http.Response, error) {
+	responseCacheLock.Lock()
+	ttl := responseCacheTTL
+	responseCacheLock.Unlock()
+	if ttl <= 0 || resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if !isSuccessBody(body) {
+		return resp, nil
+	}
+	responseCacheLock.Lock()
+	defer responseCacheLock.Unlock()
+	responseCache[key] = cachedResponse{
+		header:  resp.Header.Clone(),
+		body:    body,
+		expires: time.Now().Add(ttl),
+	}
+	return resp, nil
+}
+
+// isSuccessBody 判断响应内容是否为成功数据,接口在业务错误时也可能返回200
+func isSuccessBody(body []byte) bool {
+	var status struct {
+		Code  any `json:"code"`
+		Cod   any `json:"cod"`
+		Error any `json:"error"`
+	}
+	if json.Unmarshal(body, &status) != nil {
+		// 不是对象的数据(例如数组)直接缓存
+		return json.Valid(body)
+	}
+	if status.Code != nil && fmt.Sprint(status.Code) != "200" {
+		return false
+	}
+	if status.Cod != nil && fmt.Sprint(status.Cod) != "200" {
+		return false
+	}
+	switch v := status.Error.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,297 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BasinNP 西北太平洋,和风天气目前只支持该海域的台风数据
+const BasinNP = "NP"
+
+// StormTypes 热带气旋等级,按强度由低到高排列
+var StormTypes = []struct {
+	Type string
+	Name string
+}{
+	{"TD", "热带低压"},
+	{"TS", "热带风暴"},
+	{"STS", "强热带风暴"},
+	{"TY", "台风"},
+	{"STY", "强台风"},
+	{"SuperTY", "超强台风"},
+}
+
+// StormTypeName 热带气旋等级的中文名称,未知等级原样返回
+func StormTypeName(typ string) string {
+	for _, v := range StormTypes {
+		if v.Type == typ {
+			return v.Name
+		}
+	}
+	return typ
+}
+
+// Storm 热带气旋
+type Storm struct {
+	ID     string // 台风ID,例如NP_2421
+	Name   string // 台风名称
+	Basin  string // 所在海域
+	Year   string // 年份
+	Active bool   // 是否为活跃台风
+}
+
+// StormPoint 台风路径上的一个点
+type StormPoint struct {
+	Time      time.Time // 实况或预报时间
+	Lat       float64   // 纬度
+	Lon       float64   // 经度
+	Type      string    // 等级,见 StormTypes
+	Pressure  Quantity  // 中心气压
+	WindSpeed Quantity  // 最大风速
+	MoveSpeed Quantity  // 移动速度
+	MoveDir   string    // 移动方向
+}
+
+// Distance 与指定坐标的距离,单位公里
+func (p StormPoint) Distance(lat, lon float64) float64 {
+	return Distance(p.Lat, p.Lon, lat, lon)
+}
+
+// StormTrack 台风的实况、历史路径与预报路径
+type StormTrack struct {
+	Storm    Storm
+	Now      StormPoint   // 最新实况
+	Track    []StormPoint // 历史路径,按时间排列
+	Forecast []StormPoint // 预报路径,按时间排列,可能为空
+}
+
+// Nearest 当前位置与预报路径中离指定坐标最近的点
+func (t StormTrack) Nearest(lat, lon float64) (ret StormPoint, km float64) {
+	ret, km = t.Now, t.Now.Distance(lat, lon)
+	for _, p := range t.Forecast {
+		if d := p.Distance(lat, lon); d < km {
+			ret, km = p, d
+		}
+	}
+	return ret, km
+}
+
+// Peak 预报路径中风速最大的点,没有预报时为最新实况
+func (t StormTrack) Peak() StormPoint {
+	ret := t.Now
+	for _, p := range t.Forecast {
+		if p.WindSpeed.Valid && (!ret.WindSpeed.Valid || p.WindSpeed.Value > ret.WindSpeed.Value) {
+			ret = p
+		}
+	}
+	return ret
+}
+
+// Distance 计算两个坐标之间的大圆距离,单位公里
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371.0
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Bearing 由第一个坐标指向第二个坐标的方位角,0为正北,顺时针增加
+func Bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLon := (lon2 - lon1) * rad
+	y := math.Sin(dLon) * math.Cos(lat2*rad)
+	x := math.Cos(lat1*rad)*math.Sin(lat2*rad) - math.Sin(lat1*rad)*math.Cos(lat2*rad)*math.Cos(dLon)
+	return math.Mod(math.Atan2(y, x)/rad+360, 360)
+}
+
+// BearingName 方位角对应的八方位名称
+func BearingName(deg float64) string {
+	i := int(math.Round(math.Mod(deg+360, 360)/45)) % 8
+	return strings.TrimSuffix(windDirsZh[i], "风")
+}
+
+// StormProvider 支持台风数据的数据源
+type StormProvider interface {
+	GetActiveStorms(ctx context.Context, basin string) ([]StormTrack, error)
+}
+
+type stormListRaw struct {
+	Code   string `json:"code"`
+	FxLink string `json:"fxLink"`
+	Storm  []struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Basin    string `json:"basin"`
+		Year     string `json:"year"`
+		IsActive string `json:"isActive"`
+	} `json:"storm"`
+}
+
+type stormPointRaw struct {
+	PubTime   string `json:"pubTime"`
+	Time      string `json:"time"`
+	FxTime    string `json:"fxTime"`
+	Lat       string `json:"lat"`
+	Lon       string `json:"lon"`
+	Type      string `json:"type"`
+	Pressure  string `json:"pressure"`
+	WindSpeed string `json:"windSpeed"`
+	MoveSpeed string `json:"moveSpeed"`
+	MoveDir   string `json:"moveDir"`
+}
+
+// point 解析为路径点,坐标无法解析时返回false
+func (r stormPointRaw) point() (ret StormPoint, ok bool) {
+	var err1, err2 error
+	ret.Lat, err1 = strconv.ParseFloat(r.Lat, 64)
+	ret.Lon, err2 = strconv.ParseFloat(r.Lon, 64)
+	if err1 != nil || err2 != nil {
+		return ret, false
+	}
+	for _, s := range []string{r.PubTime, r.Time, r.FxTime} {
+		if t, err := time.Parse(qweatherTimeLayout, s); err == nil {
+			ret.Time = t
+			break
+		}
+	}
+	ret.Type = r.Type
+	ret.Pressure = ParseQuantity(r.Pressure, UnitHPa)
+	ret.WindSpeed = ParseQuantity(r.WindSpeed, UnitMs)
+	ret.MoveSpeed = ParseQuantity(r.MoveSpeed, UnitKmh)
+	ret.MoveDir = r.MoveDir
+	return ret, true
+}
+
+type stormTrackRaw struct {
+	Code     string          `json:"code"`
+	FxLink   string          `json:"fxLink"`
+	IsActive string          `json:"isActive"`
+	Now      stormPointRaw   `json:"now"`
+	Track    []stormPointRaw `json:"track"`
+}
+
+type stormForecastRaw struct {
+	Code     string          `json:"code"`
+	FxLink   string          `json:"fxLink"`
+	Forecast []stormPointRaw `json:"forecast"`
+}
+
+// parseStormPoints 解析路径点并按时间排序,跳过坐标无效的点
+func parseStormPoints(raw []stormPointRaw) []StormPoint {
+	ret := make([]StormPoint, 0, len(raw))
+	for _, v := range raw {
+		if p, ok := v.point(); ok {
+			ret = append(ret, p)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Time.Before(ret[j].Time)
+	})
+	return ret
+}
+
+// GetStormList 通过和风天气接口获取某一年的台风列表
+//
+// basin: 海域,目前只支持 BasinNP
+func GetStormList(host, key, basin string, year int, opt RequestOptions) ([]Storm, error) {
+	if len(key) == 0 && opt.Signer == nil {
+		return nil, errors.New("台风接口需要秘钥")
+	}
+	opt.Unit = ""
+	raw := stormListRaw{}
+	rawURL := fmt.Sprintf("%s/tropical/storm-list?basin=%s&year=%d%s", host, url.QueryEscape(basin), year, opt.query(key))
+	if err := getQWeatherJson(rawURL, opt, &raw, &raw.Code); err != nil {
+		return nil, err
+	}
+	ret := make([]Storm, 0, len(raw.Storm))
+	for _, v := range raw.Storm {
+		ret = append(ret, Storm{
+			ID:     v.ID,
+			Name:   v.Name,
+			Basin:  v.Basin,
+			Year:   v.Year,
+			Active: v.IsActive == "1",
+		})
+	}
+	return ret, nil
+}
+
+// GetStormTrack 通过和风天气接口获取台风实况与路径,并尝试获取预报路径
+//
+// 预报路径获取失败时 Forecast 为空,不影响实况数据
+func GetStormTrack(host, key string, storm Storm, opt RequestOptions) (ret StormTrack, err error) {
+	if len(key) == 0 && opt.Signer == nil {
+		return ret, errors.New("台风接口需要秘钥")
+	}
+	opt.Unit = ""
+	ret.Storm = storm
+	raw := stormTrackRaw{}
+	rawURL := fmt.Sprintf("%s/tropical/storm-track?stormid=%s%s", host, url.QueryEscape(storm.ID), opt.query(key))
+	if err = getQWeatherJson(rawURL, opt, &raw, &raw.Code); err != nil {
+		return ret, err
+	}
+	ret.Track = parseStormPoints(raw.Track)
+	now, ok := raw.Now.point()
+	if !ok {
+		if len(ret.Track) == 0 {
+			return ret, &APIError{Provider: ProviderQWeather, Endpoint: endpointOf(rawURL), Code: "200", Message: "缺少台风实况数据", Err: ErrMalformedResponse}
+		}
+		now = ret.Track[len(ret.Track)-1]
+	}
+	ret.Now = now
+	if raw.IsActive != "" {
+		ret.Storm.Active = raw.IsActive == "1"
+	}
+	forecast := stormForecastRaw{}
+	rawURL = fmt.Sprintf("%s/tropical/storm-forecast?stormid=%s%s", host, url.QueryEscape(storm.ID), opt.query(key))
+	if getQWeatherJson(rawURL, opt, &forecast, &forecast.Code) == nil {
+		ret.Forecast = parseStormPoints(forecast.Forecast)
+	}
+	return ret, nil
+}
+
+// GetActiveStorms 获取海域内所有活跃台风的路径
+//
+// 一月时同时查询上一年,跨年的台风仍归在上一年。
+// 单个台风的路径获取失败时跳过,只有全部失败时才返回错误
+func GetActiveStorms(host, key, basin string, now time.Time, opt RequestOptions) ([]StormTrack, error) {
+	years := []int{now.Year()}
+	if now.Month() == time.January {
+		years = append(years, now.Year()-1)
+	}
+	var (
+		ret     []StormTrack
+		lastErr error
+	)
+	for _, year := range years {
+		storms, err := GetStormList(host, key, basin, year, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, storm := range storms {
+			if !storm.Active {
+				continue
+			}
+			track, err := GetStormTrack(host, key, storm, opt)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			ret = append(ret, track)
+		}
+	}
+	if len(ret) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return ret, nil
+}
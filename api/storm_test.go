@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetActiveStormsSkipsFailedTrack(t *testing.T) {
+	SetResponseCacheTTL(0)
+	defer SetResponseCacheTTL(10 * time.Minute)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/tropical/storm-list":
+			if q.Get("basin") != BasinNP {
+				t.Errorf("basin = %q", q.Get("basin"))
+			}
+			w.Write([]byte(`{"code":"200","storm":[
+				{"id":"NP_2601","name":"一号","basin":"NP","year":"2026","isActive":"1"},
+				{"id":"NP_2602&x=1","name":"二号","basin":"NP","year":"2026","isActive":"1"},
+				{"id":"NP_2603","name":"三号","basin":"NP","year":"2026","isActive":"0"}]}`))
+		case "/tropical/storm-track":
+			// 带特殊字符的台风ID应原样到达接口
+			if q.Get("stormid") != "NP_2601" {
+				if q.Get("stormid") != "NP_2602&x=1" {
+					t.Errorf("stormid = %q", q.Get("stormid"))
+				}
+				w.Write([]byte(`{"code":"204"}`))
+				return
+			}
+			w.Write([]byte(`{"code":"200","isActive":"1","now":{"pubTime":"2026-10-19T12:00+08:00","lat":"20.1","lon":"125.3","type":"TY","pressure":"960","windSpeed":"40"}}`))
+		case "/tropical/storm-forecast":
+			w.Write([]byte(`{"code":"200","forecast":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local)
+	tracks, err := GetActiveStorms(srv.URL, "test", BasinNP, now, RequestOptions{})
+	if err != nil {
+		t.Fatalf("GetActiveStorms() error = %v", err)
+	}
+	if len(tracks) != 1 || tracks[0].Storm.ID != "NP_2601" || tracks[0].Now.Lat != 20.1 {
+		t.Errorf("GetActiveStorms() = %+v, want only NP_2601", tracks)
+	}
+
+	// 全部失败时返回错误,不当作没有活跃台风
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tropical/storm-list" {
+			w.Write([]byte(`{"code":"200","storm":[{"id":"NP_2602","isActive":"1"}]}`))
+			return
+		}
+		w.Write([]byte(`{"code":"204"}`))
+	})
+	if _, err := GetActiveStorms(srv.URL, "test", BasinNP, now, RequestOptions{}); !errors.Is(err, ErrNoData) {
+		t.Errorf("GetActiveStorms() with every track failing error = %v, want %v", err, ErrNoData)
+	}
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// TideEvent 满潮或干潮
+type TideEvent struct {
+	Time   time.Time // 潮汐时间,使用站点所在时区
+	Height float64   // 潮高,单位米
+	High   bool      // 是否为满潮
+}
+
+// TideHeight 某一时刻的潮高
+type TideHeight struct {
+	Time   time.Time // 时间,使用站点所在时区
+	Height float64   // 潮高,单位米
+}
+
+// TideTable 某个潮汐站点一天的潮汐表
+type TideTable struct {
+	Station string       // 潮汐站点POI ID
+	Date    string       // 日期,2006-01-02
+	Events  []TideEvent  // 满潮与干潮,按时间排列
+	Hourly  []TideHeight // 逐小时潮高,按时间排列,可能为空
+}
+
+// Range 当天潮高的最小值与最大值,包含满潮干潮与逐小时数据
+func (t TideTable) Range() (low, high float64) {
+	first := true
+	add := func(v float64) {
+		if first || v < low {
+			low = v
+		}
+		if first || v > high {
+			high = v
+		}
+		first = false
+	}
+	for _, v := range t.Events {
+		add(v.Height)
+	}
+	for _, v := range t.Hourly {
+		add(v.Height)
+	}
+	return low, high
+}
+
+// Next 指定时间之后的第一次满潮或干潮
+func (t TideTable) Next(now time.Time) (TideEvent, bool) {
+	for _, v := range t.Events {
+		if v.Time.After(now) {
+			return v, true
+		}
+	}
+	return TideEvent{}, false
+}
+
+// TideProvider 支持潮汐数据的数据源
+type TideProvider interface {
+	GetTide(ctx context.Context, poiID string, date time.Time) (TideTable, error)
+}
+
+type tideRaw struct {
+	Code      string `json:"code"`
+	FxLink    string `json:"fxLink"`
+	TideTable []struct {
+		FxTime string `json:"fxTime"`
+		Height string `json:"height"`
+		Type   string `json:"type"`
+	} `json:"tideTable"`
+	TideHourly []struct {
+		FxTime string `json:"fxTime"`
+		Height string `json:"height"`
+	} `json:"tideHourly"`
+}
+
+// GetTide 通过和风天气接口获取潮汐表
+//
+// poiID: 潮汐站点的POI ID,可通过POI搜索接口以type=TSTA查询
+func GetTide(poiID, host, key string, date time.Time, opt RequestOptions) (ret TideTable, err error) {
+	if len(key) == 0 && opt.Signer == nil {
+		return ret, errors.New("潮汐接口需要秘钥")
+	}
+	if poiID == "" {
+		return ret, errors.New("潮汐站点不能为空")
+	}
+	// 潮高固定使用米
+	opt.Unit = "m"
+	raw := tideRaw{}
+	rawURL := fmt.Sprintf("%s/ocean/tide?location=%s&date=%s%s", host, url.QueryEscape(poiID), date.Format("20060102"), opt.query(key))
+	if err = getQWeatherJson(rawURL, opt, &raw, &raw.Code); err != nil {
+		return ret, err
+	}
+	ret.Station = poiID
+	ret.Date = date.Format("2006-01-02")
+	for _, v := range raw.TideTable {
+		t, err1 := time.Parse(qweatherTimeLayout, v.FxTime)
+		h, err2 := strconv.ParseFloat(v.Height, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		ret.Events = append(ret.Events, TideEvent{Time: t, Height: h, High: v.Type == "H"})
+	}
+	for _, v := range raw.TideHourly {
+		t, err1 := time.Parse(qweatherTimeLayout, v.FxTime)
+		h, err2 := strconv.ParseFloat(v.Height, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		ret.Hourly = append(ret.Hourly, TideHeight{Time: t, Height: h})
+	}
+	if len(ret.Events) == 0 && len(ret.Hourly) == 0 {
+		return ret, &APIError{Provider: ProviderQWeather, Endpoint: endpointOf(rawURL), Code: "200", Message: "缺少潮汐数据", Err: ErrMalformedResponse}
+	}
+	sort.SliceStable(ret.Events, func(i, j int) bool {
+		return ret.Events[i].Time.Before(ret.Events[j].Time)
+	})
+	sort.SliceStable(ret.Hourly, func(i, j int) bool {
+		return ret.Hourly[i].Time.Before(ret.Hourly[j].Time)
+	})
+	return ret, nil
+}
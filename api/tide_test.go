@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetTide(t *testing.T) {
+	SetResponseCacheTTL(0)
+	defer SetResponseCacheTTL(10 * time.Minute)
+	// 带特殊字符的站点ID应原样到达接口,不会拆分出其他参数
+	const poiID = "P2951&date=19700101"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("location") != poiID || q.Get("date") != "20261019" {
+			t.Errorf("location = %q, date = %q", q.Get("location"), q.Get("date"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"code":"200","tideTable":[
+			{"fxTime":"2026-10-19T15:10+08:00","height":"0.60","type":"L"},
+			{"fxTime":"2026-10-19T08:45+08:00","height":"2.31","type":"H"}]}`))
+	}))
+	defer srv.Close()
+
+	date := time.Date(2026, 10, 19, 12, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	table, err := GetTide(poiID, srv.URL, "test", date, RequestOptions{})
+	if err != nil {
+		t.Fatalf("GetTide() error = %v", err)
+	}
+	if len(table.Events) != 2 || !table.Events[0].High || table.Events[1].High {
+		t.Fatalf("Events = %+v, want high then low", table.Events)
+	}
+	if next, ok := table.Next(date); !ok || next.Time.Format("15:04") != "15:10" {
+		t.Errorf("Next(12:00) = %v %v, want 15:10", next.Time, ok)
+	}
+	// 当天最后一次潮汐之后没有下一次
+	if _, ok := table.Next(date.Add(4 * time.Hour)); ok {
+		t.Error("Next(16:00) ok = true, want false")
+	}
+}
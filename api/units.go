@@ -0,0 +1,241 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	UnitMph  Unit = "mph"
+	UnitMs   Unit = "m/s"
+	UnitInHg Unit = "inHg"
+	UnitMile Unit = "mi"
+	UnitInch Unit = "in"
+)
+
+// UnitSystem 各类数值的显示单位
+type UnitSystem struct {
+	Temperature Unit `json:"temperature"` // 温度,°C或°F
+	WindSpeed   Unit `json:"wind_speed"`  // 风速,km/h、mph或m/s
+	Pressure    Unit `json:"pressure"`    // 气压,hPa或inHg
+	Visibility  Unit `json:"visibility"`  // 能见度,km或mi
+	Precip      Unit `json:"precip"`      // 降水量,mm或in
+}
+
+var (
+	// MetricUnits 公制
+	MetricUnits = UnitSystem{
+		Temperature: UnitCelsius,
+		WindSpeed:   UnitKmh,
+		Pressure:    UnitHPa,
+		Visibility:  UnitKm,
+		Precip:      UnitMm,
+	}
+	// ImperialUnits 英制
+	ImperialUnits = UnitSystem{
+		Temperature: UnitFahrenheit,
+		WindSpeed:   UnitMph,
+		Pressure:    UnitInHg,
+		Visibility:  UnitMile,
+		Precip:      UnitInch,
+	}
+	// qweatherImperialUnits 和风天气英制接口返回的单位,气压仍为百帕
+	qweatherImperialUnits = UnitSystem{
+		Temperature: UnitFahrenheit,
+		WindSpeed:   UnitMph,
+		Pressure:    UnitHPa,
+		Visibility:  UnitMile,
+		Precip:      UnitInch,
+	}
+)
+
+// 单位换算到基准单位的系数,基准单位为每一类的公制单位
+var unitFactors = map[Unit]float64{
+	UnitKmh:  1,
+	UnitMph:  1.609344,
+	UnitMs:   3.6,
+	UnitHPa:  1,
+	UnitInHg: 33.8639,
+	UnitKm:   1,
+	UnitMile: 1.609344,
+	UnitMm:   1,
+	UnitInch: 25.4,
+}
+
+// Convert 换算到指定单位,单位不同类或无法换算时原样返回
+func (q Quantity) Convert(to Unit) Quantity {
+	if !q.Valid || to == "" || q.Unit == to {
+		return q
+	}
+	switch {
+	case q.Unit == UnitCelsius && to == UnitFahrenheit:
+		return Quantity{Value: q.Value*9/5 + 32, Unit: to, Valid: true}
+	case q.Unit == UnitFahrenheit && to == UnitCelsius:
+		return Quantity{Value: (q.Value - 32) * 5 / 9, Unit: to, Valid: true}
+	}
+	from, ok1 := unitFactors[q.Unit]
+	target, ok2 := unitFactors[to]
+	if !ok1 || !ok2 || unitKind(q.Unit) != unitKind(to) {
+		return q
+	}
+	return Quantity{Value: q.Value * from / target, Unit: to, Valid: true}
+}
+
+func unitKind(u Unit) string {
+	switch u {
+	case UnitCelsius, UnitFahrenheit:
+		return "temperature"
+	case UnitKmh, UnitMph, UnitMs:
+		return "speed"
+	case UnitHPa, UnitInHg:
+		return "pressure"
+	case UnitKm, UnitMile:
+		return "distance"
+	case UnitMm, UnitInch:
+		return "length"
+	}
+	return string(u)
+}
+
+// Apply 将实时天气换算为该单位制
+func (u UnitSystem) Apply(o Observation) Observation {
+	o.Temp = o.Temp.Convert(u.Temperature)
+	o.FeelsLike = o.FeelsLike.Convert(u.Temperature)
+	o.Dew = o.Dew.Convert(u.Temperature)
+	o.WindSpeed = o.WindSpeed.Convert(u.WindSpeed)
+	o.Pressure = o.Pressure.Convert(u.Pressure)
+	o.Vis = o.Vis.Convert(u.Visibility)
+	o.Precip = o.Precip.Convert(u.Precip)
+	return o
+}
+
+// ApplyWeather 将天气数据的所有部分换算为该单位制
+//
+// Now 直接换算;实时天气、逐小时与逐天预报的文字数据按单位的显示精度重新格式化,
+// 换算后 Units 为该单位制。单位制为空时原样返回
+func (u UnitSystem) ApplyWeather(w Weather) Weather {
+	if u == (UnitSystem{}) {
+		return w
+	}
+	from, to := w.Units.withDefault(), u.withDefault()
+	conv := func(s *string, unit, target Unit) {
+		q := ParseQuantity(*s, unit)
+		if q.Valid {
+			q = q.Convert(target)
+			*s = q.Format(q.Unit.precision())
+		}
+	}
+	w.Now = u.Apply(w.Now)
+	c := &w.Current
+	conv(&c.Temp, from.Temperature, to.Temperature)
+	conv(&c.FeelsLike, from.Temperature, to.Temperature)
+	conv(&c.Dew, from.Temperature, to.Temperature)
+	conv(&c.WindSpeed, from.WindSpeed, to.WindSpeed)
+	conv(&c.Precip, from.Precip, to.Precip)
+	conv(&c.Pressure, from.Pressure, to.Pressure)
+	conv(&c.Vis, from.Visibility, to.Visibility)
+	// 复制切片,避免修改缓存或记录中共用的数据
+	w.Hourly = append([]HourlyForecast(nil), w.Hourly...)
+	for i := range w.Hourly {
+		h := &w.Hourly[i]
+		conv(&h.Temp, from.Temperature, to.Temperature)
+		conv(&h.Dew, from.Temperature, to.Temperature)
+		conv(&h.WindSpeed, from.WindSpeed, to.WindSpeed)
+		conv(&h.Precip, from.Precip, to.Precip)
+		conv(&h.Pressure, from.Pressure, to.Pressure)
+	}
+	w.Forecast = append([]DailyForecast(nil), w.Forecast...)
+	for i := range w.Forecast {
+		d := &w.Forecast[i]
+		conv(&d.TempMax, from.Temperature, to.Temperature)
+		conv(&d.TempMin, from.Temperature, to.Temperature)
+		conv(&d.WindSpeedDay, from.WindSpeed, to.WindSpeed)
+		conv(&d.WindSpeedNight, from.WindSpeed, to.WindSpeed)
+		conv(&d.Precip, from.Precip, to.Precip)
+		conv(&d.Pressure, from.Pressure, to.Pressure)
+		conv(&d.Vis, from.Visibility, to.Visibility)
+	}
+	w.Units = to
+	return w
+}
+
+// withDefault 未设置的单位使用公制
+func (u UnitSystem) withDefault() UnitSystem {
+	if u.Temperature == "" {
+		u.Temperature = MetricUnits.Temperature
+	}
+	if u.WindSpeed == "" {
+		u.WindSpeed = MetricUnits.WindSpeed
+	}
+	if u.Pressure == "" {
+		u.Pressure = MetricUnits.Pressure
+	}
+	if u.Visibility == "" {
+		u.Visibility = MetricUnits.Visibility
+	}
+	if u.Precip == "" {
+		u.Precip = MetricUnits.Precip
+	}
+	return u
+}
+
+// QWeatherUnit 获取和风天气接口的 unit 参数
+//
+// 接口只支持整体切换,英制返回i,其他返回m,由换算层处理其余单位
+func (u UnitSystem) QWeatherUnit() string {
+	if u == ImperialUnits {
+		return "i"
+	}
+	return "m"
+}
+
+// ParseUnitSystem 解析单位制配置
+//
+// name: metric、imperial或custom,为空时使用公制
+// custom: 自定义单位,格式为 temp=°F,wind=mph,pressure=inHg,vis=mi,precip=in, 未配置的项使用公制
+func ParseUnitSystem(name, custom string) (UnitSystem, error) {
+	switch name {
+	case "", "metric":
+		return MetricUnits, nil
+	case "imperial":
+		return ImperialUnits, nil
+	case "custom":
+	default:
+		return MetricUnits, fmt.Errorf("未知单位制:%s", name)
+	}
+	ret := MetricUnits
+	for _, item := range strings.Split(custom, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return ret, fmt.Errorf("自定义单位格式错误:%s", item)
+		}
+		unit := Unit(strings.TrimSpace(value))
+		// 温度单位允许省略°
+		if unit == "C" || unit == "F" {
+			unit = "°" + unit
+		}
+		var target *Unit
+		switch strings.TrimSpace(key) {
+		case "temp":
+			target = &ret.Temperature
+		case "wind":
+			target = &ret.WindSpeed
+		case "pressure":
+			target = &ret.Pressure
+		case "vis":
+			target = &ret.Visibility
+		case "precip":
+			target = &ret.Precip
+		default:
+			return ret, fmt.Errorf("未知单位项:%s", key)
+		}
+		if unitKind(unit) != unitKind(*target) {
+			return ret, fmt.Errorf("单位%s不能用于%s", unit, key)
+		}
+		*target = unit
+	}
+	return ret, nil
+}
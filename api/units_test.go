@@ -0,0 +1,54 @@
+package api
+
+import "testing"
+
+func TestQuantityString(t *testing.T) {
+	tests := []struct {
+		q    Quantity
+		want string
+	}{
+		{Quantity{Value: 18.4, Unit: UnitCelsius, Valid: true}, "18°C"},
+		{Quantity{Value: 0.3, Unit: UnitMm, Valid: true}, "0.3mm"},
+		{Quantity{Value: 0.3, Unit: UnitMm, Valid: true}.Convert(UnitInch), "0.01in"},
+		{Quantity{Value: 1016, Unit: UnitHPa, Valid: true}.Convert(UnitInHg), "30.00inHg"},
+		{Quantity{Value: 10, Unit: UnitKm, Valid: true}.Convert(UnitMile), "6.2mi"},
+		{Quantity{Unit: UnitMm}, "--"},
+	}
+	for _, tt := range tests {
+		if got := tt.q.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestApplyWeather(t *testing.T) {
+	w := Weather{
+		Current:  WeatherStatus{Temp: "20", Precip: "2.5", Pressure: "1016", Vis: "10"},
+		Hourly:   []HourlyForecast{{Temp: "10", WindSpeed: "16", Precip: "25.4"}},
+		Forecast: []DailyForecast{{TempMax: "30", TempMin: "-5", Precip: "0"}},
+	}
+	w.parse()
+	got := ImperialUnits.ApplyWeather(w)
+	if got.Units != ImperialUnits {
+		t.Errorf("Units = %+v, want %+v", got.Units, ImperialUnits)
+	}
+	if got.Now.Temp.String() != "68°F" || got.Now.Precip.String() != "0.10in" {
+		t.Errorf("Now = %s %s, want 68°F 0.10in", got.Now.Temp, got.Now.Precip)
+	}
+	if c := got.Current; c.Temp != "68" || c.Precip != "0.10" || c.Pressure != "30.00" || c.Vis != "6.2" {
+		t.Errorf("Current = %+v", c)
+	}
+	if h := got.Hourly[0]; h.Temp != "50" || h.WindSpeed != "10" || h.Precip != "1.00" {
+		t.Errorf("Hourly[0] = %+v", h)
+	}
+	if d := got.Forecast[0]; d.TempMax != "86" || d.TempMin != "23" || d.Precip != "0.00" {
+		t.Errorf("Forecast[0] = %+v", d)
+	}
+	// 原数据不受影响
+	if w.Hourly[0].Temp != "10" || w.Forecast[0].TempMax != "30" {
+		t.Error("ApplyWeather modified the original slices")
+	}
+	if same := MetricUnits.ApplyWeather(w); same.Current.Temp != "20" || same.Hourly[0].Precip != "25.4" {
+		t.Errorf("metric ApplyWeather changed values: %+v", same)
+	}
+}
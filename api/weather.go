@@ -1,15 +1,28 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
-	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// 所有接口共用的客户端,带有超时、限流与响应缓存
+var httpClient = &http.Client{
+	Timeout:   15 * time.Second,
+	Transport: &limitTransport{base: http.DefaultTransport},
+}
+
+// DefaultSharedEndpoint 默认的共享接口地址
+const DefaultSharedEndpoint = "https://openapi.hyiy.top/api/life/weather"
+
 type weatherResp struct {
-	Error int         `json:"error"`
+	Error int         `json:"error"` // 错误码,0为成功
+	Msg   string      `json:"msg"`   // 错误信息,可能为空
 	Data  WeatherResp `json:"data"`
 }
 
@@ -61,24 +74,92 @@ type WeatherIndexs struct {
 	Sunscreen WeatherIndexStatus `json:"sunscreen"` // 防晒指数
 }
 type WeatherIndexStatus struct {
+	Type     string `json:"type,omitempty"` // 指数类型ID
+	Date     string `json:"date,omitempty"` // 预报日期
 	Name     string `json:"name"`
 	Level    string `json:"level"`
 	Category string `json:"category"`
 	Text     string `json:"text"`
 }
 
-// GetWeather 获取天气信息
+var (
+	sharedEndpointLock sync.Mutex
+	sharedEndpoint     = DefaultSharedEndpoint
+)
+
+// SetSharedEndpoint 设置 GetWeather 使用的共享接口地址,为空时恢复默认地址
+func SetSharedEndpoint(endpoint string) {
+	sharedEndpointLock.Lock()
+	defer sharedEndpointLock.Unlock()
+	if endpoint == "" {
+		endpoint = DefaultSharedEndpoint
+	}
+	sharedEndpoint = endpoint
+}
+
+func getSharedEndpoint() string {
+	sharedEndpointLock.Lock()
+	defer sharedEndpointLock.Unlock()
+	return sharedEndpoint
+}
+
+// GetWeather 通过共享接口获取天气信息,地址可通过 SetSharedEndpoint 修改
 func GetWeather(cityID string) (WeatherResp, error) {
+	return GetWeatherFrom(getSharedEndpoint(), cityID)
+}
+
+// GetWeatherFrom 通过指定的共享接口获取天气信息
+//
+// endpoint: 共享接口地址,可以是自建的 cmd/weather-proxy
+func GetWeatherFrom(endpoint, cityID string) (WeatherResp, error) {
+	return getWeatherFrom(context.Background(), endpoint, cityID)
+}
+
+func getWeatherFrom(ctx context.Context, endpoint, cityID string) (WeatherResp, error) {
 	ret := weatherResp{}
-	resp, err := http.Post("http://openapi.hyiy.top/api/life/weather", "application/x-www-form-urlencoded", strings.NewReader("cityID="+cityID))
+	form := url.Values{"cityID": {cityID}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
 	if err != nil {
 		return ret.Data, err
 	}
-	defer resp.Body.Close()
-	respData, err := io.ReadAll(resp.Body)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	respData, status, err := doRequest(req)
 	if err != nil {
 		return ret.Data, err
 	}
 	err = json.Unmarshal(respData, &ret)
-	return ret.Data, err
+	if err != nil {
+		return ret.Data, newDecodeError(ProviderShared, endpoint, status, err)
+	}
+	if ret.Error != 0 {
+		apiErr := &APIError{
+			Provider: ProviderShared,
+			Endpoint: endpointOf(endpoint),
+			Status:   status,
+			Code:     strconv.Itoa(ret.Error),
+			Message:  ret.Msg,
+			Err:      ErrUnknown,
+		}
+		if v, ifSet := codeErrors[ret.Error]; ifSet {
+			apiErr.Err = v
+		} else if ret.Error >= 500 && ret.Error < 600 {
+			apiErr.Err = ErrServer
+		}
+		return ret.Data, apiErr
+	}
+	if err = newAPIError(ProviderShared, endpoint, status, ""); err != nil {
+		return ret.Data, err
+	}
+	// 缺少实时天气时不能渲染,视为格式错误
+	now := ret.Data.WeatherStatus
+	if now.Temp == "" || now.Icon == "" || now.Text == "" {
+		return ret.Data, &APIError{
+			Provider: ProviderShared,
+			Endpoint: endpointOf(endpoint),
+			Status:   status,
+			Message:  "缺少实时天气数据",
+			Err:      ErrMalformedResponse,
+		}
+	}
+	return ret.Data, nil
 }
@@ -1,12 +1,13 @@
 package api
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
 )
 
 // 城市数据
@@ -14,6 +15,7 @@ type cityInteface struct {
 	citys     []citys
 	Datas     map[string]cityCache
 	DatasList map[string]cityCache // 单层字典,键为城市代码/值为地区
+	Locations map[string]Location  // 单层字典,键为城市代码/值为地区坐标
 }
 type citys struct {
 	Iso3166   string   `json:"ISO_3166"`
@@ -47,6 +49,54 @@ type cityCache struct {
 	Son  map[string]cityCache // 一层层嵌套的字典,键为省/城市/地区名
 }
 
+// RequestOptions 和风天气请求的可选参数
+type RequestOptions struct {
+	Unit   string       // 度量衡单位, m为公制, i为英制, 为空使用接口默认
+	Lang   string       // 多语言设置,例如zh、en, 为空使用接口默认
+	Signer *TokenSigner // JWT签名器,设置后使用请求头认证,不再传递key
+
+	Context context.Context // 取消或到达截止时间时停止请求,为空时不限制
+}
+
+// context 请求使用的上下文
+func (o RequestOptions) context() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
+}
+
+// query 拼接到请求地址后的公共参数,参数值均经过转义
+func (o RequestOptions) query(key string) string {
+	ret := ""
+	if o.Signer == nil {
+		ret += "&key=" + url.QueryEscape(key)
+	}
+	if o.Unit != "" {
+		ret += "&unit=" + url.QueryEscape(o.Unit)
+	}
+	if o.Lang != "" {
+		ret += "&lang=" + url.QueryEscape(o.Lang)
+	}
+	return ret
+}
+
+// qweatherGet 请求和风天气接口,返回响应内容与HTTP状态码
+func qweatherGet(url string, opt RequestOptions) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(opt.context(), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if opt.Signer != nil {
+		token, err := opt.Signer.Token()
+		if err != nil {
+			return nil, 0, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return doRequest(req)
+}
+
 type CityWeatherInfo struct {
 	Code       string        `json:"code"`
 	UpdateTime string        `json:"updateTime"`
@@ -68,6 +118,7 @@ func initWeatherData() error {
 	}
 	cityDatas.Datas = make(map[string]cityCache)
 	cityDatas.DatasList = make(map[string]cityCache)
+	cityDatas.Locations = make(map[string]Location)
 	for _, cityData := range cityDatas.citys {
 		for _, province := range cityData.Regions {
 			if _, ifSet := cityDatas.Datas[province.Name]; !ifSet {
@@ -93,6 +144,7 @@ func initWeatherData() error {
 							Son:  nil,
 						}
 						cityDatas.DatasList[county.LocationID] = cityDatas.Datas[province.Name].Son[city.Name].Son[county.Location]
+						cityDatas.Locations[county.LocationID] = county
 					}
 				}
 			}
@@ -101,59 +153,60 @@ func initWeatherData() error {
 	return nil
 }
 
-func checkRespCode(code string) error {
-	if code == "200" {
-		return nil
-	} else if code == "204" {
-		return errors.New("城市数据不存在")
-	} else if code == "400" {
-		return errors.New("请求错误")
-	} else if code == "401" {
-		return errors.New("认证失败,请联系管理员")
-	} else if code == "402" {
-		return errors.New("超过访问次数,请联系管理员")
-	} else if code == "403" {
-		return errors.New("无访问权限,请联系管理员")
-	} else if code == "404" {
-		return errors.New("数据或地区不存在")
-	} else if code == "429" {
-		return errors.New("超过限制访问次数,请稍后再试")
-	} else if code == "500" {
-		return errors.New("服务器内部错误,请联系管理员")
-	} else {
-		return errors.New(fmt.Sprintf("未知错误,错误码:%s", code))
+// GetLocation 获取城市坐标
+func GetLocation(cityID string) (Location, error) {
+	if len(cityDatas.citys) == 0 {
+		if err := initWeatherData(); err != nil {
+			return Location{}, err
+		}
 	}
+	loc, ifSet := cityDatas.Locations[cityID]
+	if !ifSet {
+		return loc, errors.New("城市ID不存在")
+	}
+	return loc, nil
+}
+
+// checkRespCode 检查和风天气返回码
+//
+// 和风天气的响应总是带有返回码,HTTP状态正常但缺少返回码(例如 {})视为响应格式错误
+func checkRespCode(rawURL string, status int, code string) error {
+	if code == "" && (status == 0 || status == http.StatusOK) {
+		return &APIError{
+			Provider: ProviderQWeather,
+			Endpoint: endpointOf(rawURL),
+			Status:   status,
+			Message:  "缺少返回码",
+			Err:      ErrMalformedResponse,
+		}
+	}
+	return newAPIError(ProviderQWeather, rawURL, status, code)
 }
 
 // GetCurrentWeather 获取当前天气
-func GetCurrentWeather(cityID, host, key string) (ret CityWeatherInfo, raw []byte, err error) {
+func GetCurrentWeather(cityID, host, key string, opt RequestOptions) (ret CityWeatherInfo, raw []byte, err error) {
 	if len(cityDatas.citys) == 0 {
 		if err := initWeatherData(); err != nil {
 			return ret, nil, err
 		}
 	}
-	if _, ifSet := cityDatas.DatasList[cityID]; !ifSet && len(key) == 0 {
+	if _, ifSet := cityDatas.DatasList[cityID]; !ifSet && len(key) == 0 && opt.Signer == nil {
 		return ret, nil, errors.New("城市ID不存在\n请注意,国际城市ID需要使用开发或付费接口")
 	}
-	url := fmt.Sprintf("%s/weather/now?location=%s&key=%s",
+	rawURL := fmt.Sprintf("%s/weather/now?location=%s%s",
 		host,
-		cityID,
-		key,
+		url.QueryEscape(cityID),
+		opt.query(key),
 	)
-	resp, err := http.Get(url)
-	if err != nil {
-		return ret, nil, err
-	}
-	defer resp.Body.Close()
-	respData, err := io.ReadAll(resp.Body)
+	respData, status, err := qweatherGet(rawURL, opt)
 	if err != nil {
 		return ret, nil, err
 	}
 	err = json.Unmarshal(respData, &ret)
 	if err != nil {
-		return ret, nil, err
+		return ret, nil, newDecodeError(ProviderQWeather, rawURL, status, err)
 	}
-	err = checkRespCode(ret.Code)
+	err = checkRespCode(rawURL, status, ret.Code)
 	if err != nil {
 		return ret, nil, err
 	}
@@ -164,10 +217,11 @@ type CityWeatherIndexInfo struct {
 	Code       string        `json:"code"`
 	UpdateTime string        `json:"updateTime"`
 	FxLink     string        `json:"fxLink"`
-	Index      WeatherIndexs `json:"index"` // 生活指数
+	Index      WeatherIndexs `json:"index"` // 当天的生活指数
+	Days       []IndexSet    `json:"days"`  // 按日期排列的生活指数,包含未知类型
 }
 
-func convertWeatherIndex(data []byte) (CityWeatherIndexInfo, error) {
+func convertWeatherIndex(url string, status int, data []byte) (CityWeatherIndexInfo, error) {
 	type cityWeatherIndexRaw struct {
 		Code       string `json:"code"`
 		UpdateTime string `json:"updateTime"`
@@ -185,105 +239,70 @@ func convertWeatherIndex(data []byte) (CityWeatherIndexInfo, error) {
 	raw := cityWeatherIndexRaw{}
 	err := json.Unmarshal(data, &raw)
 	if err != nil {
-		return ret, err
+		return ret, newDecodeError(ProviderQWeather, url, status, err)
 	}
-	err = checkRespCode(raw.Code)
+	err = checkRespCode(url, status, raw.Code)
 	if err != nil {
 		return ret, err
 	}
 	ret.Code = raw.Code
 	ret.UpdateTime = raw.UpdateTime
 	ret.FxLink = raw.FxLink
+	// 多天的指数按日期分组,接口按日期顺序返回
+	days := make(map[string]int)
 	for _, v := range raw.Daily {
-		var tmpStatus *WeatherIndexStatus
-		switch v.Type {
-		case "1":
-			// 运动指数
-			tmpStatus = &ret.Index.Motion
-		case "2":
-			// 洗车指数
-			tmpStatus = &ret.Index.CarWash
-		case "3":
-			// 穿衣指数
-			tmpStatus = &ret.Index.Dress
-		case "4":
-			// 钓鱼指数
-			tmpStatus = &ret.Index.Fishing
-		case "5":
-			// 紫外线指数
-			tmpStatus = &ret.Index.UV
-		case "6":
-			// 旅游指数
-			tmpStatus = &ret.Index.Travel
-		case "7":
-			// 过敏指数
-			tmpStatus = &ret.Index.Allergy
-		case "8":
-			// 舒适度指数
-			tmpStatus = &ret.Index.Comfort
-		case "9":
-			// 感冒指数
-			tmpStatus = &ret.Index.Cold
-		case "10":
-			// 空气污染扩散条件指数
-			tmpStatus = &ret.Index.Air
-		case "11":
-			// 空调开启指数
-			tmpStatus = &ret.Index.Ac
-		case "12":
-			// 太阳镜指数
-			tmpStatus = &ret.Index.Sunglass
-		case "13":
-			// 化妆指数
-			tmpStatus = &ret.Index.Makeup
-		case "14":
-			// 晾晒指数
-			tmpStatus = &ret.Index.Dry
-		case "15":
-			// 交通指数
-			tmpStatus = &ret.Index.Traffic
-		case "16":
-			// 防晒指数
-			tmpStatus = &ret.Index.Sunscreen
-		default:
-			continue
+		i, ifSet := days[v.Date]
+		if !ifSet {
+			i = len(ret.Days)
+			days[v.Date] = i
+			ret.Days = append(ret.Days, make(IndexSet))
+		}
+		ret.Days[i][v.Type] = WeatherIndexStatus{
+			Type:     v.Type,
+			Date:     v.Date,
+			Name:     v.Name,
+			Level:    v.Level,
+			Category: v.Category,
+			Text:     v.Text,
 		}
-		tmpStatus.Name = v.Name
-		tmpStatus.Level = v.Level
-		tmpStatus.Category = v.Category
-		tmpStatus.Text = v.Text
+	}
+	if len(ret.Days) > 0 {
+		ret.Index = ret.Days[0].Indexs()
 	}
 	return ret, nil
 }
 
-// GetWeatherIndex 获取当前天气
+// GetWeatherIndex 获取当天生活指数
 //
 // cityID: 城市ID
-// 内置限流,每秒10个令牌,令牌桶容量30个
-func GetWeatherIndex(cityID, host, key string) (ret CityWeatherIndexInfo, raw []byte, err error) {
+// 请求经过限流,默认所有接口共用每秒10个令牌、容量30个的令牌桶,各数据源的限流由 SetProviderRateLimits 配置
+func GetWeatherIndex(cityID, host, key string, opt RequestOptions) (ret CityWeatherIndexInfo, raw []byte, err error) {
+	return GetWeatherIndexDays(cityID, host, key, 1, opt)
+}
+
+// GetWeatherIndexDays 获取多天生活指数
+//
+// days: 预报天数,支持1天与3天
+func GetWeatherIndexDays(cityID, host, key string, days int, opt RequestOptions) (ret CityWeatherIndexInfo, raw []byte, err error) {
 	if len(cityDatas.citys) == 0 {
 		if err := initWeatherData(); err != nil {
 			return ret, nil, err
 		}
 	}
-	if _, ifSet := cityDatas.DatasList[cityID]; !ifSet && len(key) == 0 {
+	if _, ifSet := cityDatas.DatasList[cityID]; !ifSet && len(key) == 0 && opt.Signer == nil {
 		return ret, nil, errors.New("城市ID不存在\n请注意,国际城市ID需要使用开发或付费接口")
 	}
-	url := fmt.Sprintf("%s/indices/1d?type=0&location=%s&key=%s",
+	rawURL := fmt.Sprintf("%s/indices/%dd?type=0&location=%s%s",
 		host,
-		cityID,
-		key,
+		days,
+		url.QueryEscape(cityID),
+		opt.query(key),
 	)
-	resp, err := http.Get(url)
-	if err != nil {
-		return ret, nil, err
-	}
-	defer resp.Body.Close()
-	respData, err := io.ReadAll(resp.Body)
+	respData, status, err := qweatherGet(rawURL, opt)
 	if err != nil {
 		return ret, nil, err
 	}
-	ret, err = convertWeatherIndex(respData)
+	ret, err = convertWeatherIndex(rawURL, status, respData)
 	if err != nil {
 		return ret, nil, err
 	}
@@ -0,0 +1,111 @@
+// weather-export 导出插件保存的本地天气记录
+//
+// 读取插件目录下的 observations_城市ID.jsonl,导出为CSV或SQLite数据库,
+// 数值均换算为公制
+//
+//	go build -o weather-export ./cmd/weather-export
+//	./weather-export -path /插件目录 -city 101280601 -o weather.csv
+//	./weather-export -path /插件目录 -city 101280601 -from 2024-01-01 -o weather.db
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"hw_weather_plugin/api"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	formatCSV    = "csv"
+	formatSQLite = "sqlite"
+)
+
+func main() {
+	path := flag.String("path", ".", "插件目录")
+	cityID := flag.String("city", "", "城市ID")
+	format := flag.String("format", "", "导出格式 csv 或 sqlite,为空时按输出文件扩展名判断")
+	output := flag.String("o", "", "输出文件,为空时输出CSV到标准输出")
+	from := flag.String("from", "", "开始日期,例如 2024-01-01")
+	to := flag.String("to", "", "结束日期,包含当天")
+	flag.Parse()
+
+	if *cityID == "" {
+		log.Fatal("城市ID不能为空")
+	}
+	if *format == "" {
+		*format = formatOf(*output)
+	}
+	if *format != formatCSV && *format != formatSQLite {
+		log.Fatalf("未知导出格式:%s", *format)
+	}
+	if *format == formatSQLite && *output == "" {
+		log.Fatal("导出SQLite需要指定输出文件")
+	}
+	start, err := parseDate(*from)
+	if err != nil {
+		log.Fatalf("开始日期格式错误:%s", *from)
+	}
+	end, err := parseDate(*to)
+	if err != nil {
+		log.Fatalf("结束日期格式错误:%s", *to)
+	}
+	if !end.IsZero() {
+		end = end.AddDate(0, 0, 1)
+	}
+
+	records, err := api.NewObservationLog(*path).Records(*cityID, start, end)
+	if err != nil {
+		log.Fatalf("读取天气记录失败:%v", err)
+	}
+	if len(records) == 0 {
+		log.Fatalf("没有城市%s的天气记录", *cityID)
+	}
+	var buf bytes.Buffer
+	if *format == formatSQLite {
+		err = api.WriteObservationsSQLite(&buf, records)
+	} else {
+		err = api.WriteObservationsCSV(&buf, records)
+	}
+	if err != nil {
+		log.Fatalf("导出失败:%v", err)
+	}
+	if err = write(*output, buf.Bytes()); err != nil {
+		log.Fatalf("写入失败:%v", err)
+	}
+	if *output != "" {
+		fmt.Fprintf(os.Stderr, "已导出%d条记录到%s\n", len(records), *output)
+	}
+}
+
+// formatOf 按文件扩展名判断导出格式
+func formatOf(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".db", ".sqlite", ".sqlite3":
+		return formatSQLite
+	}
+	return formatCSV
+}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, time.Local)
+}
+
+// write 写入输出文件,为空时写到标准输出
+//
+// SQLite文件整体生成后再写入,避免覆盖已有文件时留下不完整的数据库
+func write(name string, data []byte) error {
+	if name == "" {
+		_, err := io.Copy(os.Stdout, bytes.NewReader(data))
+		return err
+	}
+	return os.WriteFile(name, data, 0644)
+}
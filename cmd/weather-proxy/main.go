@@ -0,0 +1,228 @@
+// weather-proxy 可自行部署的共享天气接口
+//
+// 与插件共享接口相同的调用方式: POST 表单参数 cityID,
+// 返回 {"error": 0, "data": WeatherResp},数据来自配置的和风天气秘钥
+//
+//	go build -o weather-proxy ./cmd/weather-proxy
+//	QWEATHER_KEY=xxx ./weather-proxy -addr :8080
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"hw_weather_plugin/api"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 错误码,与和风天气的错误码含义一致
+const (
+	codeOK          = 0
+	codeBadRequest  = 400
+	codeNotFound    = 404
+	codeMethod      = 405
+	codeRateLimited = 429
+	codeUpstream    = 502
+)
+
+type weatherResp struct {
+	Error int              `json:"error"`
+	Msg   string           `json:"msg,omitempty"`
+	Data  *api.WeatherResp `json:"data,omitempty"`
+}
+
+type cacheEntry struct {
+	data    api.WeatherResp
+	expires time.Time
+}
+
+type clientLimiter struct {
+	limiter  *api.RateLimiter
+	lastSeen time.Time
+}
+
+type server struct {
+	host       string
+	key        string
+	opt        api.RequestOptions
+	cacheTTL   time.Duration
+	rate       float64
+	burst      int
+	trustProxy bool
+
+	cacheLock sync.Mutex
+	cache     map[string]cacheEntry
+
+	clientLock sync.Mutex
+	clients    map[string]*clientLimiter
+}
+
+// 城市ID只允许字母与数字,避免拼接到上游地址时注入参数
+var cityIDPattern = regexp.MustCompile(`^[0-9A-Za-z]{1,32}$`)
+
+func main() {
+	addr := flag.String("addr", ":8080", "监听地址")
+	path := flag.String("path", "/api/life/weather", "接口路径")
+	host := flag.String("host", "https://devapi.qweather.com/v7", "和风天气接口地址,也可填写控制台中的个人API Host")
+	key := flag.String("key", os.Getenv("QWEATHER_KEY"), "和风天气秘钥,默认读取环境变量QWEATHER_KEY")
+	projectID := flag.String("project-id", "", "和风天气项目ID,使用JWT认证时填写")
+	keyID := flag.String("key-id", "", "和风天气凭据ID,使用JWT认证时填写")
+	privateKey := flag.String("private-key", "", "Ed25519私钥文件(PEM格式),使用JWT认证时填写")
+	cacheTTL := flag.Duration("cache", 10*time.Minute, "同一城市的天气缓存时长")
+	rate := flag.Float64("rate", 1, "每个客户端每秒允许的请求数")
+	burst := flag.Int("burst", 5, "每个客户端允许的突发请求数")
+	trustProxy := flag.Bool("trust-proxy", false, "部署在反向代理后时,使用X-Forwarded-For识别客户端")
+	quotaFile := flag.String("quota-file", "", "请求次数统计文件,为空则不持久化")
+	flag.Parse()
+
+	s := &server{
+		host:       api.NormalizeQWeatherHost(*host),
+		key:        *key,
+		opt:        api.RequestOptions{Lang: "zh"},
+		cacheTTL:   *cacheTTL,
+		rate:       *rate,
+		burst:      *burst,
+		trustProxy: *trustProxy,
+		cache:      make(map[string]cacheEntry),
+		clients:    make(map[string]*clientLimiter),
+	}
+	if *keyID != "" {
+		pem, err := os.ReadFile(*privateKey)
+		if err != nil {
+			log.Fatalf("读取私钥失败:%v", err)
+		}
+		s.opt.Signer, err = api.NewTokenSigner(*projectID, *keyID, string(pem))
+		if err != nil {
+			log.Fatalf("JWT配置错误:%v", err)
+		}
+	} else if s.key == "" {
+		log.Fatal("需要配置和风天气秘钥或JWT凭据")
+	}
+	if *quotaFile != "" {
+		if err := api.SetQuotaFile(*quotaFile); err != nil {
+			log.Printf("载入请求次数统计失败:%v", err)
+		}
+	}
+	// 上游接口已由本服务缓存,关闭接口层的响应缓存避免双重缓存
+	api.SetResponseCacheTTL(0)
+
+	go s.cleanupLoop()
+	mux := http.NewServeMux()
+	mux.HandleFunc(*path, s.handleWeather)
+	log.Printf("共享天气接口已启动:%s%s", *addr, *path)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func (s *server) handleWeather(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeResp(w, http.StatusMethodNotAllowed, weatherResp{Error: codeMethod, Msg: "仅支持POST请求"})
+		return
+	}
+	client := s.clientIP(r)
+	if !s.limiterFor(client).Allow() {
+		writeResp(w, http.StatusTooManyRequests, weatherResp{Error: codeRateLimited, Msg: "请求过于频繁,请稍后再试"})
+		return
+	}
+	cityID := strings.TrimSpace(r.PostFormValue("cityID"))
+	if !cityIDPattern.MatchString(cityID) {
+		writeResp(w, http.StatusBadRequest, weatherResp{Error: codeBadRequest, Msg: "城市ID格式错误"})
+		return
+	}
+	data, err := s.getWeather(cityID)
+	if err != nil {
+		log.Printf("获取天气失败,客户端:%s,城市:%s,%v", client, cityID, err)
+		if errors.Is(err, api.ErrNoData) || errors.Is(err, api.ErrNotFound) {
+			writeResp(w, http.StatusNotFound, weatherResp{Error: codeNotFound, Msg: err.Error()})
+			return
+		}
+		writeResp(w, http.StatusBadGateway, weatherResp{Error: codeUpstream, Msg: "获取天气失败"})
+		return
+	}
+	writeResp(w, http.StatusOK, weatherResp{Error: codeOK, Data: &data})
+}
+
+// getWeather 获取城市天气,缓存有效期内直接返回缓存
+func (s *server) getWeather(cityID string) (api.WeatherResp, error) {
+	s.cacheLock.Lock()
+	entry, ifSet := s.cache[cityID]
+	s.cacheLock.Unlock()
+	if ifSet && time.Now().Before(entry.expires) {
+		return entry.data, nil
+	}
+	status, _, err := api.GetCurrentWeather(cityID, s.host, s.key, s.opt)
+	if err != nil {
+		return api.WeatherResp{}, err
+	}
+	// 生活指数获取失败时仍返回实时天气
+	indexs, _, err := api.GetWeatherIndex(cityID, s.host, s.key, s.opt)
+	if err != nil {
+		log.Printf("获取生活指数失败,城市:%s,%v", cityID, err)
+	}
+	ret := api.WeatherResp{}
+	ret.Parse(&status, &indexs)
+	s.cacheLock.Lock()
+	s.cache[cityID] = cacheEntry{data: ret, expires: time.Now().Add(s.cacheTTL)}
+	s.cacheLock.Unlock()
+	return ret, nil
+}
+
+// clientIP 获取客户端地址
+func (s *server) clientIP(r *http.Request) string {
+	if s.trustProxy {
+		if v := r.Header.Get("X-Forwarded-For"); v != "" {
+			ip, _, _ := strings.Cut(v, ",")
+			return strings.TrimSpace(ip)
+		}
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+func (s *server) limiterFor(client string) *api.RateLimiter {
+	s.clientLock.Lock()
+	defer s.clientLock.Unlock()
+	c, ifSet := s.clients[client]
+	if !ifSet {
+		c = &clientLimiter{limiter: api.NewRateLimiter(s.rate, s.burst)}
+		s.clients[client] = c
+	}
+	c.lastSeen = time.Now()
+	return c.limiter
+}
+
+// cleanupLoop 定期清理过期的缓存与长时间未访问的客户端
+func (s *server) cleanupLoop() {
+	for range time.Tick(time.Minute) {
+		now := time.Now()
+		s.cacheLock.Lock()
+		for k, v := range s.cache {
+			if now.After(v.expires) {
+				delete(s.cache, k)
+			}
+		}
+		s.cacheLock.Unlock()
+		s.clientLock.Lock()
+		for k, v := range s.clients {
+			if now.Sub(v.lastSeen) > 10*time.Minute {
+				delete(s.clients, k)
+			}
+		}
+		s.clientLock.Unlock()
+	}
+}
+
+func writeResp(w http.ResponseWriter, status int, resp weatherResp) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
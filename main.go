@@ -1,8 +1,8 @@
 package main
 
 func main() {
-	// data, err := weather.DerawImage("8D298", "https://devapi.qweather.com/v7", "", "今日待办", "买菜\n抽卡\n打游戏\n学习\n看电影\n吃饭\n睡觉", true)
-	// // data, err := weather.DerawImage("101280610", "", "", "今日待办", "买菜\n抽卡\n打游戏\n学习\n看电影\n吃饭\n睡觉", true)
+	// data, err := weather.DerawImage(api.NewQWeatherProvider("https://devapi.qweather.com/v7", ""), weather.Options{CityID: "8D298", AddiTitle: "今日待办", AddiContent: "买菜\n抽卡\n打游戏\n学习\n看电影\n吃饭\n睡觉", Units: api.ImperialUnits})
+	// // data, err := weather.DerawImage(api.NewSharedProvider(), weather.Options{CityID: "101280610", AddiTitle: "今日待办", AddiContent: "买菜\n抽卡\n打游戏\n学习\n看电影\n吃饭\n睡觉", Units: api.ImperialUnits})
 	// if err != nil {
 	// 	panic(err)
 	// }
@@ -28,11 +28,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hw_weather_plugin/api"
 	"hw_weather_plugin/utils/utils"
 	"hw_weather_plugin/weather"
 	"io"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 	"unsafe"
 )
@@ -72,9 +76,33 @@ type Widget struct {
 
 type ConfigPut struct {
 	CityID             string `json:"city_id"`
+	WeatherProvider    string `json:"weather_provider"`
 	WeatherKey         string `json:"weather_key"`
 	WeatherApiBusiness bool   `json:"weather_api_business"`
+	QWeatherHost       string `json:"qweather_host"`
+	QWeatherProjectID  string `json:"qweather_project_id"`
+	QWeatherKeyID      string `json:"qweather_key_id"`
+	QWeatherPrivateKey string `json:"qweather_private_key"`
+	OpenWeatherKey     string `json:"openweather_key"`
+	SharedEndpoint     string `json:"shared_endpoint"`
+	RateLimits         string `json:"rate_limits"`
+	QuotaLimit         string `json:"quota_limit"`
+	QuotaFallback      string `json:"quota_fallback"`
+	ResponseCacheTTL   string `json:"response_cache_ttl"`
 	EnableFahrenheit   bool   `json:"enable_fahrenheit"`
+	UnitSystem         string `json:"unit_system"`
+	Lang               string `json:"lang"`
+	CustomUnits        string `json:"custom_units"`
+	EnableAstronomy    bool   `json:"enable_astronomy"`
+	AstronomyApi       bool   `json:"astronomy_api"`
+	ShowIndices        string `json:"show_indices"`
+	ShowWidgets        string `json:"show_widgets"`
+	IndicesTomorrow    bool   `json:"indices_tomorrow"`
+	Layout             string `json:"layout"`
+	StormRadius        string `json:"storm_radius"`
+	TidePOI            string `json:"tide_poi"`
+	RecordDays         string `json:"record_days"`
+	RecordLimit        string `json:"record_limit"`
 	AddiTitle          string `json:"addi_title"`
 	AddiContent        string `json:"addi_content"`
 }
@@ -88,12 +116,22 @@ type SubmitData struct {
 
 const (
 	PluginName = "生活插件"
+	// 一次刷新获取数据的截止时间
+	fetchTimeout = 30 * time.Second
+	// 天气获取的截止时间,早于整体截止时间,超时后仍有时间读取磁盘缓存
+	weatherTimeout = fetchTimeout - 5*time.Second
 )
 
 var (
 	pluginConfig  PluginConfig
 	configPutData ConfigPut
 	lastError     error
+	// 小组件轮换序号,每次刷新加1
+	widgetRotation int
+	// 本地历史天气,插件初始化时创建
+	weatherHistory *api.HistoryStore
+	// 本地天气记录,插件初始化时创建
+	weatherObservations *api.ObservationLog
 
 	//go:embed Description.txt
 	description string
@@ -174,6 +212,11 @@ func PluginInit(config *C.char) bool {
 	}
 	defer f.Close()
 	data, _ := io.ReadAll(f)
+	if err = api.SetQuotaFile(filepath.Join(pluginConfig.Path, "quota.json")); err != nil {
+		lastError = err
+	}
+	weatherHistory = api.NewHistoryStore(pluginConfig.Path)
+	weatherObservations = api.NewObservationLog(pluginConfig.Path)
 	return json.Unmarshal(data, &configPutData) == nil
 }
 
@@ -209,6 +252,195 @@ func PluginConfigUI() *C.char {
 					Layout: 10,
 				},
 			},
+			{
+				{
+					Type:   "text",
+					Text:   "API Host",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "qweather_host",
+					Text:   configPutData.QWeatherHost,
+					Layout: 7,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "控制台中的个人API Host,例如abc1234xyz.def.qweatherapi.com,留空则使用公共地址",
+					Layout: 10,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "项目ID",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "qweather_project_id",
+					Text:   configPutData.QWeatherProjectID,
+					Layout: 3,
+				},
+				{
+					Type:   "text",
+					Text:   "凭据ID",
+					Layout: 1,
+				},
+				{
+					Type:   "input",
+					Bind:   "qweather_key_id",
+					Text:   configPutData.QWeatherKeyID,
+					Layout: 3,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "私钥",
+					Layout: 2,
+				},
+				{
+					Type:   "input_ml",
+					Bind:   "qweather_private_key",
+					Text:   configPutData.QWeatherPrivateKey,
+					Height: 80,
+					Layout: 7,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "填写项目ID、凭据ID与Ed25519私钥(PEM格式)后使用JWT认证,无需填写秘钥",
+					Layout: 10,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "每日次数上限",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "quota_limit",
+					Text:   configPutData.QuotaLimit,
+					Layout: 3,
+				},
+				{
+					Type:   "text",
+					Text:   fmt.Sprintf("今日已请求%d次", api.QuotaUsed(qweatherCredential())),
+					Layout: 4,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "超出后",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "quota_fallback",
+					Text:   utils.Ifs(configPutData.QuotaFallback == "", "cache", configPutData.QuotaFallback),
+					Layout: 7,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "可选cache(仅使用缓存)/shared(改用共享接口),上限留空则不限制",
+					Layout: 10,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "OpenWeatherMap秘钥",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "openweather_key",
+					Text:   configPutData.OpenWeatherKey,
+					Layout: 7,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "共享接口地址",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "shared_endpoint",
+					Text:   configPutData.SharedEndpoint,
+					Layout: 7,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "自建共享接口时填写,留空则使用" + api.DefaultSharedEndpoint,
+					Layout: 10,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "数据源",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "weather_provider",
+					Text:   configPutData.WeatherProvider,
+					Layout: 7,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "可选qweather/shared/openmeteo/openweather,多个以逗号分隔按顺序自动切换,留空则自动选择",
+					Layout: 10,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "限流",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "rate_limits",
+					Text:   configPutData.RateLimits,
+					Layout: 7,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "格式为 数据源=每秒次数/突发次数,例如qweather=10/30,留空使用默认值",
+					Layout: 10,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "缓存(分钟)",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "response_cache_ttl",
+					Text:   utils.Ifs(configPutData.ResponseCacheTTL == "", "10", configPutData.ResponseCacheTTL),
+					Layout: 7,
+				},
+			},
 			// ------------------------
 			{
 				{
@@ -225,6 +457,208 @@ func PluginConfigUI() *C.char {
 					Layout: 3,
 				},
 			},
+			{
+				{
+					Type:   "text",
+					Text:   "语言",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "lang",
+					Text:   configPutData.Lang,
+					Layout: 7,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "天气描述的语言,例如zh/en,留空为中文,共享接口不支持",
+					Layout: 10,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "单位制",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "unit_system",
+					Text:   utils.Ifs(configPutData.UnitSystem == "", "metric", configPutData.UnitSystem),
+					Layout: 7,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "自定义单位",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "custom_units",
+					Text:   configPutData.CustomUnits,
+					Layout: 7,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "单位制可选metric/imperial/custom,自定义格式为temp=F,wind=mph,pressure=inHg,vis=mi,precip=in",
+					Layout: 10,
+				},
+			},
+			{
+				{
+					Type:   "checkbox",
+					Text:   "显示日出日落与月相",
+					Bind:   "enable_astronomy",
+					Layout: 4,
+				},
+				{
+					Type:   "checkbox",
+					Text:   "天文数据使用和风接口",
+					Bind:   "astronomy_api",
+					Layout: 4,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "生活指数",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "show_indices",
+					Text:   configPutData.ShowIndices,
+					Layout: 7,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "小组件显示的指数,例如dress,uv,cold,可选" + indexKeys() + ",也可填写指数类型ID,与日出日落每次刷新轮换显示",
+					Layout: 10,
+				},
+			},
+			{
+				{
+					Type:   "checkbox",
+					Text:   "18点后显示明日指数(仅和风天气)",
+					Bind:   "indices_tomorrow",
+					Layout: 6,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "天气组件",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "show_widgets",
+					Text:   configPutData.ShowWidgets,
+					Layout: 7,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "可选" + strings.Join(weather.Widgets, "/") + "(风向/气压与能见度/体感温度/露点/与昨日及去年比较),与生活指数一起轮换显示",
+					Layout: 10,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "页面布局",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "layout",
+					Text:   utils.Ifs(configPutData.Layout == "", weather.LayoutMain, configPutData.Layout),
+					Layout: 7,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "可选main(天气主页)/indices(生活指数建议,显示上方所选指数)/storms(台风路径)/tides(潮汐)",
+					Layout: 10,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "台风提醒半径",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "storm_radius",
+					Text:   configPutData.StormRadius,
+					Layout: 3,
+				},
+				{
+					Type:   "text",
+					Text:   "公里,台风进入范围时改为显示台风路径",
+					Layout: 5,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "潮汐站点",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "tide_poi",
+					Text:   configPutData.TidePOI,
+					Layout: 3,
+				},
+				{
+					Type:   "text",
+					Text:   "潮汐站点POI ID,例如P2951",
+					Layout: 5,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "记录保留天数",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "record_days",
+					Text:   utils.Ifs(configPutData.RecordDays == "", "365", configPutData.RecordDays),
+					Layout: 3,
+				},
+				{
+					Type:   "text",
+					Text:   "最多条数",
+					Layout: 2,
+				},
+				{
+					Type:   "input",
+					Bind:   "record_limit",
+					Text:   configPutData.RecordLimit,
+					Layout: 3,
+				},
+			},
+			{
+				{
+					Type:   "text",
+					Text:   "每次获取的实时天气与生活指数保存在插件目录,填0或留空条数时不限制,可用weather-export导出为CSV或SQLite",
+					Layout: 10,
+				},
+			},
 			// ------------------------
 			{
 				{
@@ -354,48 +788,327 @@ func PluginSaveConfig(data *C.char) bool {
 	return err == nil
 }
 
-func GetWeatherImage() ([]byte, error) {
-	if configPutData.CityID == "" {
-		err := errors.New("城市ID不能为空")
-		lastError = err
-		return nil, err
-	}
-	if configPutData.WeatherKey == "" {
-		// 共享接口
-		data, err := weather.DerawImage(
-			configPutData.CityID,
-			"",
-			"",
-			configPutData.AddiTitle,
-			configPutData.AddiContent,
-			configPutData.EnableFahrenheit,
-		)
+// newWeatherProvider 根据名称创建天气数据源
+func newWeatherProvider(name string) (api.WeatherProvider, error) {
+	switch name {
+	case api.ProviderShared:
+		return newSharedProvider()
+	case api.ProviderOpenMeteo:
+		provider := api.NewOpenMeteoProvider()
+		provider.Lang = configPutData.Lang
+		return provider, nil
+	case api.ProviderOpenWeather:
+		if configPutData.OpenWeatherKey == "" {
+			return nil, errors.New("OpenWeatherMap秘钥不能为空")
+		}
+		provider := api.NewOpenWeatherProvider(configPutData.OpenWeatherKey)
+		if configPutData.Lang != "" {
+			provider.Lang = configPutData.Lang
+		}
+		return provider, nil
+	case api.ProviderQWeather:
+		signer, err := getTokenSigner()
 		if err != nil {
-			lastError = err
 			return nil, err
 		}
-		return data, nil
-	} else {
-		// 付费接口
-		data, err := weather.DerawImage(
-			configPutData.CityID,
-			utils.Ifs(
+		if configPutData.WeatherKey == "" && signer == nil {
+			return nil, errors.New("和风天气秘钥不能为空")
+		}
+		host := api.NormalizeQWeatherHost(configPutData.QWeatherHost)
+		if host == "" {
+			host = utils.Ifs(
 				configPutData.WeatherApiBusiness,
 				"https://api.qweather.com/v7",
 				"https://devapi.qweather.com/v7",
-			),
-			configPutData.WeatherKey,
-			configPutData.AddiTitle,
-			configPutData.AddiContent,
-			configPutData.EnableFahrenheit,
-		)
+			)
+		} else if u, err := url.Parse(host); err == nil {
+			api.RegisterProviderHost(api.ProviderQWeather, u.Hostname())
+		}
+		provider := api.NewQWeatherProvider(host, configPutData.WeatherKey)
+		provider.Signer = signer
+		units, err := getUnitSystem()
 		if err != nil {
-			lastError = err
 			return nil, err
 		}
-		return data, nil
+		provider.Units = units
+		provider.Lang = configPutData.Lang
+		if configPutData.IndicesTomorrow {
+			provider.IndexDays = 3
+		}
+		return newQuotaGuardProvider(provider, qweatherCredential())
+	default:
+		return nil, fmt.Errorf("未知数据源:%s", name)
+	}
+}
+
+var (
+	qweatherSigner     *api.TokenSigner
+	qweatherPrivateKey string // 签名器对应的私钥,用于判断配置是否变化
+)
+
+// getTokenSigner 根据配置获取和风天气JWT签名器,未配置时返回nil
+//
+// 配置未变化时复用同一个签名器,令牌在有效期内不会重复签发
+func getTokenSigner() (*api.TokenSigner, error) {
+	if configPutData.QWeatherProjectID == "" && configPutData.QWeatherKeyID == "" && configPutData.QWeatherPrivateKey == "" {
+		return nil, nil
+	}
+	if qweatherSigner != nil &&
+		qweatherSigner.ProjectID == configPutData.QWeatherProjectID &&
+		qweatherSigner.KeyID == configPutData.QWeatherKeyID &&
+		qweatherPrivateKey == configPutData.QWeatherPrivateKey {
+		return qweatherSigner, nil
+	}
+	signer, err := api.NewTokenSigner(configPutData.QWeatherProjectID, configPutData.QWeatherKeyID, configPutData.QWeatherPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("和风天气JWT配置错误:%w", err)
+	}
+	qweatherSigner = signer
+	qweatherPrivateKey = configPutData.QWeatherPrivateKey
+	return signer, nil
+}
+
+// qweatherCredential 和风天气账号标识,用于统计请求次数
+//
+// 使用JWT认证时为凭据ID,否则为秘钥
+func qweatherCredential() string {
+	if configPutData.QWeatherKeyID != "" && configPutData.QWeatherPrivateKey != "" {
+		return configPutData.QWeatherKeyID
+	}
+	return configPutData.WeatherKey
+}
+
+// indexKeys 所有生活指数的配置名称
+func indexKeys() string {
+	keys := make([]string, 0, len(api.IndexTypes))
+	for _, v := range api.IndexTypes {
+		keys = append(keys, v.Key)
+	}
+	return strings.Join(keys, "/")
+}
+
+// getUnitSystem 根据配置获取显示单位
+//
+// 勾选华氏度时温度始终使用华氏度
+func getUnitSystem() (api.UnitSystem, error) {
+	units, err := api.ParseUnitSystem(configPutData.UnitSystem, configPutData.CustomUnits)
+	if err != nil {
+		return units, err
+	}
+	if configPutData.EnableFahrenheit {
+		units.Temperature = api.UnitFahrenheit
+	}
+	return units, nil
+}
+
+// newSharedProvider 创建共享接口数据源,可配置为自建的共享接口
+func newSharedProvider() (api.WeatherProvider, error) {
+	provider := api.NewSharedProvider()
+	endpoint := strings.TrimSpace(configPutData.SharedEndpoint)
+	if endpoint == "" {
+		return provider, nil
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("共享接口地址格式错误:%s", configPutData.SharedEndpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/api/life/weather"
+	}
+	api.RegisterProviderHost(api.ProviderShared, u.Hostname())
+	provider.Endpoint = u.String()
+	return provider, nil
+}
+
+// newQuotaGuardProvider 为数据源加上每日接口次数保护
+//
+// 达到上限后按配置改用共享接口,或者只使用缓存数据
+func newQuotaGuardProvider(provider api.WeatherProvider, key string) (api.WeatherProvider, error) {
+	if configPutData.QuotaLimit == "" {
+		// 清除之前配置的上限,否则请求仍会被拦截
+		api.SetQuotaLimit(key, 0)
+		return provider, nil
+	}
+	limit, err := strconv.Atoi(configPutData.QuotaLimit)
+	if err != nil {
+		return nil, fmt.Errorf("每日次数上限格式错误:%s", configPutData.QuotaLimit)
+	}
+	guard := api.NewQuotaGuardProvider(provider, key, limit)
+	guard.Log = CallPluginLogFunc
+	if configPutData.QuotaFallback == api.ProviderShared {
+		if guard.Fallback, err = newSharedProvider(); err != nil {
+			return nil, err
+		}
+	}
+	return guard, nil
+}
+
+// getWeatherProvider 根据配置创建天气数据源
+//
+// 配置多个数据源时以逗号分隔,按顺序自动切换
+func getWeatherProvider() (provider api.WeatherProvider, chained bool, err error) {
+	var names []string
+	for _, name := range strings.Split(configPutData.WeatherProvider, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		// 未指定时有秘钥使用和风天气,否则使用共享接口
+		names = []string{utils.Ifs(qweatherCredential() == "", api.ProviderShared, api.ProviderQWeather)}
+	}
+	if len(names) == 1 {
+		provider, err = newWeatherProvider(names[0])
+		if err != nil {
+			return nil, false, err
+		}
+		return newCacheProvider(provider), false, nil
+	}
+	providers := make([]api.WeatherProvider, 0, len(names))
+	for _, name := range names {
+		p, err := newWeatherProvider(name)
+		if err != nil {
+			return nil, true, err
+		}
+		providers = append(providers, p)
+	}
+	failover := api.NewFailoverProvider(providers...)
+	failover.Log = CallPluginLogFunc
+	return newCacheProvider(failover), true, nil
+}
+
+// newCacheProvider 为数据源加上磁盘缓存,获取失败时使用最后一次成功的数据
+func newCacheProvider(provider api.WeatherProvider) api.WeatherProvider {
+	cache := api.NewCacheProvider(provider, pluginConfig.Path)
+	cache.Timeout = weatherTimeout
+	cache.Log = CallPluginLogFunc
+	return cache
+}
+
+// applyRateLimits 应用限流与响应缓存配置
+//
+// 限流格式为 数据源=每秒令牌数/令牌桶容量,多个以逗号分隔,例如 qweather=10/30,openmeteo=1/5
+func applyRateLimits() error {
+	ttl := 10
+	if configPutData.ResponseCacheTTL != "" {
+		v, err := strconv.Atoi(configPutData.ResponseCacheTTL)
+		if err != nil {
+			return fmt.Errorf("缓存时间格式错误:%s", configPutData.ResponseCacheTTL)
+		}
+		ttl = v
+	}
+	api.SetResponseCacheTTL(time.Duration(ttl) * time.Minute)
+	// 每次按完整配置重建,删除的数据源恢复使用共用限流器
+	limits := make(map[string]api.RateLimit)
+	for _, item := range strings.Split(configPutData.RateLimits, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, limit, ok1 := strings.Cut(item, "=")
+		rateStr, capStr, ok2 := strings.Cut(limit, "/")
+		rate, err1 := strconv.ParseFloat(strings.TrimSpace(rateStr), 64)
+		capacity, err2 := strconv.Atoi(strings.TrimSpace(capStr))
+		if !ok1 || !ok2 || err1 != nil || err2 != nil {
+			return fmt.Errorf("限流格式错误:%s", item)
+		}
+		limits[strings.TrimSpace(name)] = api.RateLimit{Rate: rate, Capacity: capacity}
+	}
+	return api.SetProviderRateLimits(limits)
+}
+
+// applyRecordRetention 应用天气记录的保留天数与条数
+func applyRecordRetention() error {
+	days, limit := 365, 0
+	if configPutData.RecordDays != "" {
+		v, err := strconv.Atoi(configPutData.RecordDays)
+		if err != nil || v < 0 {
+			return fmt.Errorf("记录保留天数格式错误:%s", configPutData.RecordDays)
+		}
+		days = v
+	}
+	if configPutData.RecordLimit != "" {
+		v, err := strconv.Atoi(configPutData.RecordLimit)
+		if err != nil || v < 0 {
+			return fmt.Errorf("记录条数格式错误:%s", configPutData.RecordLimit)
+		}
+		limit = v
 	}
+	weatherObservations.Days = days
+	weatherObservations.MaxRecords = limit
+	return nil
+}
 
+func GetWeatherImage() ([]byte, error) {
+	if configPutData.CityID == "" {
+		err := errors.New("城市ID不能为空")
+		lastError = err
+		return nil, err
+	}
+	if err := applyRateLimits(); err != nil {
+		lastError = err
+		return nil, err
+	}
+	if err := applyRecordRetention(); err != nil {
+		lastError = err
+		return nil, err
+	}
+	provider, chained, err := getWeatherProvider()
+	if err != nil {
+		lastError = err
+		return nil, err
+	}
+	units, err := getUnitSystem()
+	if err != nil {
+		lastError = err
+		return nil, err
+	}
+	indices, err := api.ParseIndexTypes(configPutData.ShowIndices)
+	if err != nil {
+		lastError = err
+		return nil, err
+	}
+	widgets, err := weather.ParseWidgets(configPutData.ShowWidgets)
+	if err != nil {
+		lastError = err
+		return nil, err
+	}
+	stormRadius := 0.0
+	if configPutData.StormRadius != "" {
+		if stormRadius, err = strconv.ParseFloat(configPutData.StormRadius, 64); err != nil || stormRadius < 0 {
+			err = fmt.Errorf("台风提醒半径格式错误:%s", configPutData.StormRadius)
+			lastError = err
+			return nil, err
+		}
+	}
+	widgetRotation++
+	data, err := weather.DerawImage(provider, weather.Options{
+		CityID:          configPutData.CityID,
+		AddiTitle:       configPutData.AddiTitle,
+		AddiContent:     configPutData.AddiContent,
+		Units:           units,
+		EnableAstronomy: configPutData.EnableAstronomy,
+		AstronomyApi:    configPutData.AstronomyApi,
+		ShowProvider:    chained,
+		Indices:         indices,
+		Widgets:         widgets,
+		History:         weatherHistory,
+		Observations:    weatherObservations,
+		Rotation:        widgetRotation,
+		TomorrowAfter:   utils.Ifs(configPutData.IndicesTomorrow, 18, 0),
+		Layout:          strings.TrimSpace(configPutData.Layout),
+		StormRadius:     stormRadius,
+		TidePOI:         strings.TrimSpace(configPutData.TidePOI),
+		Deadline:        time.Now().Add(fetchTimeout),
+		Log:             CallPluginLogFunc,
+	})
+	if err != nil {
+		lastError = err
+		return nil, err
+	}
+	return data, nil
 }
 
 //export PluginTimedEvent
@@ -0,0 +1,291 @@
+// Package sqlite 生成只读导出用的SQLite数据库文件
+//
+// 只实现写入建表语句与数据行所需的文件格式,不依赖cgo或第三方驱动,
+// 生成的文件可直接用sqlite3或其他SQLite工具打开
+package sqlite
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	pageSize = 4096
+	// 单条记录在页内保存的最大长度,超出时需要溢出页,这里不支持
+	maxLocalPayload = pageSize - 35
+
+	pageInteriorTable = 0x05
+	pageLeafTable     = 0x0d
+)
+
+// Table 要写入的数据表
+//
+// 第i行的rowid为i+1。若首列声明为 INTEGER PRIMARY KEY,该列即rowid,行中对应的值应为nil
+type Table struct {
+	Name string  // 表名
+	SQL  string  // 建表语句
+	Rows [][]any // 数据行,值可为 nil、整数、float64、string、[]byte 或 bool
+}
+
+type cell struct {
+	rowid int64
+	data  []byte
+}
+
+type child struct {
+	page   uint32
+	maxKey int64
+}
+
+type writer struct {
+	pages [][]byte
+}
+
+// Write 将数据表写入为SQLite数据库文件
+func Write(w io.Writer, tables ...Table) error {
+	wr := &writer{pages: [][]byte{nil}} // 第1页为 sqlite_master,最后生成
+	master := make([]cell, 0, len(tables))
+	for i, t := range tables {
+		cells := make([]cell, 0, len(t.Rows))
+		for j, row := range t.Rows {
+			data, err := tableCell(int64(j+1), row)
+			if err != nil {
+				return fmt.Errorf("表%s第%d行:%w", t.Name, j+1, err)
+			}
+			cells = append(cells, cell{rowid: int64(j + 1), data: data})
+		}
+		root := wr.buildTree(cells)
+		data, err := tableCell(int64(i+1), []any{"table", t.Name, t.Name, int64(root), t.SQL})
+		if err != nil {
+			return fmt.Errorf("表%s:%w", t.Name, err)
+		}
+		master = append(master, cell{rowid: int64(i + 1), data: data})
+	}
+	page, rest := leafPage(master, 100)
+	if len(rest) > 0 {
+		return errors.New("数据表过多")
+	}
+	copy(page, fileHeader(len(wr.pages)))
+	wr.pages[0] = page
+	for _, p := range wr.pages {
+		if _, err := w.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileHeader 数据库文件头,写在第1页开头
+func fileHeader(pageCount int) []byte {
+	h := make([]byte, 100)
+	copy(h, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(h[16:], pageSize)
+	h[18], h[19] = 1, 1                                   // 读写版本,1为回滚日志模式
+	h[21], h[22], h[23] = 64, 32, 32                      // 固定的负载比例
+	binary.BigEndian.PutUint32(h[24:], 1)                 // 文件修改计数
+	binary.BigEndian.PutUint32(h[28:], uint32(pageCount)) // 页数
+	binary.BigEndian.PutUint32(h[40:], 1)                 // 结构版本
+	binary.BigEndian.PutUint32(h[44:], 4)                 // 结构格式
+	binary.BigEndian.PutUint32(h[56:], 1)                 // 文本编码,UTF-8
+	binary.BigEndian.PutUint32(h[92:], 1)                 // 与文件修改计数相同表示页数有效
+	binary.BigEndian.PutUint32(h[96:], 3040000)           // 写入的SQLite版本号
+	return h
+}
+
+// buildTree 由叶子页开始逐层生成B树,返回根页号
+func (wr *writer) buildTree(cells []cell) uint32 {
+	var level []child
+	for first := true; first || len(cells) > 0; first = false {
+		page, rest := leafPage(cells, 0)
+		level = append(level, child{page: wr.add(page), maxKey: maxKey(cells[:len(cells)-len(rest)])})
+		cells = rest
+	}
+	for len(level) > 1 {
+		var next []child
+		for len(level) > 0 {
+			page, n := interiorPage(level)
+			next = append(next, child{page: wr.add(page), maxKey: level[n-1].maxKey})
+			level = level[n:]
+		}
+		level = next
+	}
+	return level[0].page
+}
+
+func (wr *writer) add(page []byte) uint32 {
+	wr.pages = append(wr.pages, page)
+	return uint32(len(wr.pages))
+}
+
+func maxKey(cells []cell) int64 {
+	if len(cells) == 0 {
+		return 0
+	}
+	return cells[len(cells)-1].rowid
+}
+
+// leafPage 将尽可能多的记录放入一个叶子页,返回页与放不下的记录
+//
+// offset: 页头前预留的字节数,第1页为文件头的100字节
+func leafPage(cells []cell, offset int) ([]byte, []cell) {
+	page := make([]byte, pageSize)
+	headerEnd := offset + 8
+	content := pageSize
+	n := 0
+	for ; n < len(cells); n++ {
+		data := cells[n].data
+		if content-len(data) < headerEnd+2*(n+1) {
+			break
+		}
+		content -= len(data)
+		copy(page[content:], data)
+		binary.BigEndian.PutUint16(page[headerEnd+2*n:], uint16(content))
+	}
+	page[offset] = pageLeafTable
+	binary.BigEndian.PutUint16(page[offset+3:], uint16(n))
+	binary.BigEndian.PutUint16(page[offset+5:], uint16(content))
+	return page, cells[n:]
+}
+
+// interiorPage 将尽可能多的子页放入一个内部页,返回页与放入的子页数量
+//
+// 最后一个子页作为最右指针,其余子页各占一个单元。
+// 剩余子页只有一个时少放一个,避免下一页没有单元
+func interiorPage(children []child) ([]byte, int) {
+	const headerEnd = 12
+	cells := make([][]byte, 0, len(children))
+	used := headerEnd
+	for _, c := range children[:len(children)-1] {
+		data := binary.BigEndian.AppendUint32(nil, c.page)
+		data = append(data, putVarint(uint64(c.maxKey))...)
+		if used+len(data)+2 > pageSize {
+			break
+		}
+		used += len(data) + 2
+		cells = append(cells, data)
+	}
+	if len(children)-len(cells)-1 == 1 && len(cells) > 1 {
+		cells = cells[:len(cells)-1]
+	}
+	page := make([]byte, pageSize)
+	content := pageSize
+	for i, data := range cells {
+		content -= len(data)
+		copy(page[content:], data)
+		binary.BigEndian.PutUint16(page[headerEnd+2*i:], uint16(content))
+	}
+	page[0] = pageInteriorTable
+	binary.BigEndian.PutUint16(page[3:], uint16(len(cells)))
+	binary.BigEndian.PutUint16(page[5:], uint16(content))
+	binary.BigEndian.PutUint32(page[8:], children[len(cells)].page)
+	return page, len(cells) + 1
+}
+
+// tableCell 编码叶子页中的一行:负载长度、rowid与记录
+func tableCell(rowid int64, row []any) ([]byte, error) {
+	payload, err := record(row)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > maxLocalPayload {
+		return nil, fmt.Errorf("记录过长:%d字节", len(payload))
+	}
+	ret := putVarint(uint64(len(payload)))
+	ret = append(ret, putVarint(uint64(rowid))...)
+	return append(ret, payload...), nil
+}
+
+// record 按SQLite记录格式编码一行:头部为各列的类型,之后为各列的值
+func record(row []any) ([]byte, error) {
+	var types, body []byte
+	for _, v := range row {
+		typ, data, err := value(v)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, putVarint(typ)...)
+		body = append(body, data...)
+	}
+	// 头部长度包含自身,长度变化时重新计算
+	size := len(types) + 1
+	for len(putVarint(uint64(size)))+len(types) != size {
+		size = len(putVarint(uint64(size))) + len(types)
+	}
+	ret := append(putVarint(uint64(size)), types...)
+	return append(ret, body...), nil
+}
+
+// value 获取值的类型编号与编码后的数据
+func value(v any) (uint64, []byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil, nil
+	case bool:
+		if v {
+			return 9, nil, nil
+		}
+		return 8, nil, nil
+	case int:
+		return integer(int64(v))
+	case int32:
+		return integer(int64(v))
+	case int64:
+		return integer(v)
+	case uint32:
+		return integer(int64(v))
+	case float64:
+		return 7, binary.BigEndian.AppendUint64(nil, math.Float64bits(v)), nil
+	case string:
+		return uint64(13 + 2*len(v)), []byte(v), nil
+	case []byte:
+		return uint64(12 + 2*len(v)), v, nil
+	}
+	return 0, nil, fmt.Errorf("不支持的类型:%T", v)
+}
+
+// integer 使用能容纳该值的最短整数类型
+func integer(v int64) (uint64, []byte, error) {
+	switch {
+	case v == 0:
+		return 8, nil, nil
+	case v == 1:
+		return 9, nil, nil
+	}
+	sizes := []struct {
+		typ   uint64
+		bytes int
+	}{{1, 1}, {2, 2}, {3, 3}, {4, 4}, {5, 6}, {6, 8}}
+	for _, s := range sizes {
+		bits := uint(s.bytes * 8)
+		if s.bytes == 8 || (v >= -1<<(bits-1) && v < 1<<(bits-1)) {
+			data := binary.BigEndian.AppendUint64(nil, uint64(v))
+			return s.typ, data[8-s.bytes:], nil
+		}
+	}
+	return 0, nil, nil
+}
+
+// putVarint 编码SQLite的变长整数,大端序,每字节7位,第9字节使用全部8位
+func putVarint(v uint64) []byte {
+	if v > 0x00ffffffffffffff {
+		ret := make([]byte, 9)
+		ret[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			ret[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return ret
+	}
+	var buf [9]byte
+	i := len(buf) - 1
+	buf[i] = byte(v & 0x7f)
+	for v >>= 7; v > 0; v >>= 7 {
+		i--
+		buf[i] = byte(v&0x7f) | 0x80
+	}
+	return append([]byte(nil), buf[i:]...)
+}
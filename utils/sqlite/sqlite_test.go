@@ -0,0 +1,288 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// readVarint 解码SQLite的变长整数,返回值与占用的字节数
+func readVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v<<8 | uint64(b[8]), 9
+}
+
+// readRecord 按记录格式解码一行
+func readRecord(t *testing.T, b []byte) []any {
+	t.Helper()
+	size, n := readVarint(b)
+	var types []uint64
+	for n < int(size) {
+		typ, m := readVarint(b[n:])
+		types = append(types, typ)
+		n += m
+	}
+	body := b[size:]
+	ret := make([]any, 0, len(types))
+	for _, typ := range types {
+		switch {
+		case typ == 0:
+			ret = append(ret, nil)
+		case typ >= 1 && typ <= 6:
+			size := []int{0, 1, 2, 3, 4, 6, 8}[typ]
+			var buf [8]byte
+			if body[0]&0x80 != 0 {
+				buf = [8]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+			}
+			copy(buf[8-size:], body[:size])
+			ret = append(ret, int64(binary.BigEndian.Uint64(buf[:])))
+			body = body[size:]
+		case typ == 7:
+			ret = append(ret, math.Float64frombits(binary.BigEndian.Uint64(body)))
+			body = body[8:]
+		case typ == 8 || typ == 9:
+			ret = append(ret, int64(typ-8))
+		case typ >= 12 && typ%2 == 0:
+			size := int(typ-12) / 2
+			ret = append(ret, append([]byte(nil), body[:size]...))
+			body = body[size:]
+		case typ >= 13:
+			size := int(typ-13) / 2
+			ret = append(ret, string(body[:size]))
+			body = body[size:]
+		default:
+			t.Fatalf("未知的类型编号%d", typ)
+		}
+	}
+	if len(body) != 0 {
+		t.Fatalf("记录末尾多出%d字节", len(body))
+	}
+	return ret
+}
+
+// walk 遍历以root为根的B树,按顺序返回各行的rowid与记录,并返回树的层数
+func walk(t *testing.T, file []byte, root uint32) ([]int64, [][]any, int) {
+	t.Helper()
+	var (
+		rowids []int64
+		rows   [][]any
+	)
+	var visit func(no uint32, lo, hi int64) int
+	visit = func(no uint32, lo, hi int64) int {
+		page := file[int(no-1)*pageSize : int(no)*pageSize]
+		offset := 0
+		if no == 1 {
+			offset = 100
+		}
+		count := int(binary.BigEndian.Uint16(page[offset+3:]))
+		content := int(binary.BigEndian.Uint16(page[offset+5:]))
+		switch page[offset] {
+		case pageLeafTable:
+			for i := 0; i < count; i++ {
+				p := int(binary.BigEndian.Uint16(page[offset+8+2*i:]))
+				if p < content {
+					t.Fatalf("第%d页单元%d的偏移%d在内容区%d之前", no, i, p, content)
+				}
+				size, n := readVarint(page[p:])
+				rowid, m := readVarint(page[p+n:])
+				if int64(rowid) <= lo || int64(rowid) > hi {
+					t.Fatalf("第%d页的rowid %d不在(%d,%d]内", no, rowid, lo, hi)
+				}
+				start := p + n + m
+				rowids = append(rowids, int64(rowid))
+				rows = append(rows, readRecord(t, page[start:start+int(size)]))
+			}
+			return 1
+		case pageInteriorTable:
+			if count == 0 {
+				t.Fatalf("内部页%d没有单元", no)
+			}
+			depth := 0
+			for i := 0; i < count; i++ {
+				p := int(binary.BigEndian.Uint16(page[offset+12+2*i:]))
+				child := binary.BigEndian.Uint32(page[p:])
+				key, _ := readVarint(page[p+4:])
+				d := visit(child, lo, int64(key))
+				if depth != 0 && d != depth {
+					t.Fatalf("内部页%d的子树层数不一致", no)
+				}
+				depth, lo = d, int64(key)
+			}
+			right := binary.BigEndian.Uint32(page[offset+8:])
+			if d := visit(right, lo, hi); d != depth {
+				t.Fatalf("内部页%d的最右子树层数不一致", no)
+			}
+			return depth + 1
+		}
+		t.Fatalf("第%d页的类型%#x无效", no, page[offset])
+		return 0
+	}
+	depth := visit(root, math.MinInt64, math.MaxInt64)
+	return rowids, rows, depth
+}
+
+func TestPutVarint(t *testing.T) {
+	tests := []struct {
+		v    uint64
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x81, 0x00}},
+		{240, []byte{0x81, 0x70}},
+		{16383, []byte{0xff, 0x7f}},
+		{16384, []byte{0x81, 0x80, 0x00}},
+		{0x00ffffffffffffff, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}},
+		{0x0100000000000000, []byte{0x80, 0xc0, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}},
+		{math.MaxUint64, bytes.Repeat([]byte{0xff}, 9)},
+	}
+	for _, tt := range tests {
+		got := putVarint(tt.v)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("putVarint(%#x) = % x, want % x", tt.v, got, tt.want)
+		}
+		if v, n := readVarint(got); v != tt.v || n != len(got) {
+			t.Errorf("readVarint(putVarint(%#x)) = %#x/%d", tt.v, v, n)
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	tests := []struct {
+		v     any
+		typ   uint64
+		value any
+	}{
+		{nil, 0, nil},
+		{int64(0), 8, int64(0)},
+		{int64(1), 9, int64(1)},
+		{true, 9, int64(1)},
+		{false, 8, int64(0)},
+		{int64(-1), 1, int64(-1)},
+		{int64(127), 1, int64(127)},
+		{int64(128), 2, int64(128)},
+		{int64(-32768), 2, int64(-32768)},
+		{int64(1 << 23), 4, int64(1 << 23)},
+		{int64(1 << 40), 5, int64(1 << 40)},
+		{int64(math.MinInt64), 6, int64(math.MinInt64)},
+		{uint32(math.MaxUint32), 5, int64(math.MaxUint32)},
+		{21.5, 7, 21.5},
+		{"多云", 13 + 2*6, "多云"},
+		{[]byte{1, 2}, 12 + 2*2, []byte{1, 2}},
+	}
+	row := make([]any, 0, len(tests))
+	for _, tt := range tests {
+		typ, _, err := value(tt.v)
+		if err != nil || typ != tt.typ {
+			t.Errorf("value(%#v) type = %d/%v, want %d", tt.v, typ, err, tt.typ)
+		}
+		row = append(row, tt.v)
+	}
+	data, err := record(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readRecord(t, data)
+	for i, tt := range tests {
+		if fmt.Sprint(got[i]) != fmt.Sprint(tt.value) {
+			t.Errorf("column %d = %#v, want %#v", i, got[i], tt.value)
+		}
+	}
+
+	// 列数较多时头部长度本身需要两个字节
+	wide := make([]any, 200)
+	for i := range wide {
+		wide[i] = "x"
+	}
+	data, err = record(wide)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, n := readVarint(data); n != 2 || size != 202 {
+		t.Errorf("header size = %d (%d bytes), want 202 (2 bytes)", size, n)
+	}
+	if got := readRecord(t, data); len(got) != 200 || got[199] != "x" {
+		t.Errorf("wide record = %d columns", len(got))
+	}
+
+	if _, err := record([]any{struct{}{}}); err == nil {
+		t.Error("record() with unsupported type error = nil")
+	}
+	if _, err := tableCell(1, []any{strings.Repeat("x", pageSize)}); err == nil {
+		t.Error("tableCell() with oversized record error = nil")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	const n = 50000
+	obs := Table{
+		Name: "observations",
+		SQL:  "CREATE TABLE observations(id INTEGER PRIMARY KEY, city TEXT, temp REAL, note TEXT)",
+	}
+	for i := 0; i < n; i++ {
+		obs.Rows = append(obs.Rows, []any{nil, "101010100", float64(i) / 10, strings.Repeat("n", i%50)})
+	}
+	empty := Table{Name: "empty", SQL: "CREATE TABLE empty(a)"}
+	var buf bytes.Buffer
+	if err := Write(&buf, obs, empty); err != nil {
+		t.Fatal(err)
+	}
+	file := buf.Bytes()
+	if len(file)%pageSize != 0 {
+		t.Fatalf("文件长度%d不是页大小的整数倍", len(file))
+	}
+	if !bytes.HasPrefix(file, []byte("SQLite format 3\x00")) {
+		t.Fatalf("文件头 = %q", file[:16])
+	}
+	if size := binary.BigEndian.Uint16(file[16:]); size != pageSize {
+		t.Errorf("页大小 = %d, want %d", size, pageSize)
+	}
+	if pages := binary.BigEndian.Uint32(file[28:]); int(pages) != len(file)/pageSize {
+		t.Errorf("文件头页数 = %d, want %d", pages, len(file)/pageSize)
+	}
+	if file[100] != pageLeafTable {
+		t.Errorf("第1页类型 = %#x, want %#x", file[100], pageLeafTable)
+	}
+
+	_, master, _ := walk(t, file, 1)
+	if len(master) != 2 {
+		t.Fatalf("sqlite_master 有%d行, want 2", len(master))
+	}
+	for i, tbl := range []Table{obs, empty} {
+		m := master[i]
+		if m[0] != "table" || m[1] != tbl.Name || m[2] != tbl.Name || m[4] != tbl.SQL {
+			t.Errorf("sqlite_master[%d] = %v", i, m)
+		}
+	}
+
+	root := uint32(master[0][3].(int64))
+	rowids, rows, depth := walk(t, file, root)
+	if depth < 3 {
+		t.Errorf("B树层数 = %d, want 至少3层以覆盖多层内部页", depth)
+	}
+	if len(rows) != n {
+		t.Fatalf("读出%d行, want %d", len(rows), n)
+	}
+	for i, row := range rows {
+		if rowids[i] != int64(i+1) {
+			t.Fatalf("第%d行 rowid = %d, want %d", i, rowids[i], i+1)
+		}
+		if row[0] != nil || row[1] != "101010100" || row[2] != float64(i)/10 || row[3] != strings.Repeat("n", i%50) {
+			t.Fatalf("第%d行 = %v", i, row)
+		}
+	}
+
+	root = uint32(master[1][3].(int64))
+	if _, rows, depth := walk(t, file, root); len(rows) != 0 || depth != 1 {
+		t.Errorf("空表 = %d行/%d层, want 单个空叶子页", len(rows), depth)
+	}
+}
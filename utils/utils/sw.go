@@ -6,3 +6,10 @@ func Ifs[T any](a bool, b, c T) T {
 	}
 	return c
 }
+
+func Abs[T ~int | ~int64 | ~float64](v T) T {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
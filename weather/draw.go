@@ -1,14 +1,15 @@
 package weather
 
 import (
+	"context"
 	_ "embed"
 	"errors"
 	"fmt"
 	"hw_weather_plugin/Draw"
 	"hw_weather_plugin/api"
 	stringsPkg "hw_weather_plugin/utils/strings"
+	"hw_weather_plugin/utils/utils"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -18,7 +19,83 @@ var (
 	humidityPNG []byte
 )
 
-func DerawImage(cityID, host, weatherKey, addiTitle, addiContent string, enableFahrenheit bool) ([]byte, error) {
+// Options 绘制选项
+type Options struct {
+	CityID          string              // 城市ID
+	AddiTitle       string              // 附加标题
+	AddiContent     string              // 附加内容
+	Units           api.UnitSystem      // 显示单位
+	EnableAstronomy bool                // 显示日出日落与月相
+	AstronomyApi    bool                // 数据源支持时使用接口获取天文数据
+	ShowProvider    bool                // 在底部显示实际使用的数据源
+	Indices         []api.IndexType     // 小组件显示的生活指数
+	Widgets         []string            // 小组件显示的天气组件,见 Widgets
+	History         *api.HistoryStore   // 本地历史天气,设置后记录每次获取的天气,可为空
+	Observations    *api.ObservationLog // 本地天气记录,设置后追加每次获取的实时天气与生活指数,可为空
+	Rotation        int                 // 小组件轮换序号,每次刷新加1,显示多个小组件时依次轮换
+	TomorrowAfter   int                 // 该小时及之后显示明日的生活指数,为0时始终显示当天
+	Layout          string              // 页面布局,为空时为天气主页
+	StormRadius     float64             // 台风当前位置或预报路径进入该半径(公里)时改为显示台风页,为0时不检查
+	TidePOI         string              // 潮汐站点POI ID,潮汐页使用
+
+	Deadline time.Time        // 获取数据的共享截止时间,台风检查与所选页面的全部请求在此时取消,为零值时不限制
+	Log      func(msg string) // 日志输出,可为空
+}
+
+func (opt Options) log(format string, args ...any) {
+	if opt.Log != nil {
+		opt.Log(fmt.Sprintf(format, args...))
+	}
+}
+
+// context 获取数据使用的上下文,到达共享截止时间时取消未完成的请求
+func (opt Options) context() (context.Context, context.CancelFunc) {
+	if opt.Deadline.IsZero() {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithDeadline(context.Background(), opt.Deadline)
+}
+
+// recordWeather 记录获取到的天气,记录失败只输出日志,不影响显示
+func recordWeather(opt Options, w api.Weather) {
+	if opt.History != nil {
+		if err := opt.History.Record(opt.CityID, w); err != nil {
+			opt.log("本地历史天气记录失败:%v", err)
+		}
+	}
+	if opt.Observations != nil {
+		if err := opt.Observations.Append(opt.CityID, w); err != nil {
+			opt.log("本地天气记录写入失败:%v", err)
+		}
+	}
+}
+
+func DerawImage(provider api.WeatherProvider, opt Options) ([]byte, error) {
+	// 所有请求共用同一个截止时间,返回时取消仍未完成的请求
+	ctx, cancel := opt.context()
+	defer cancel()
+	if opt.Layout == LayoutStorms {
+		data, err := fetchStorms(ctx, provider, opt, 0)
+		if err != nil {
+			return nil, err
+		}
+		return drawStormPage(opt, data)
+	}
+	if opt.StormRadius > 0 {
+		// 台风数据获取失败时照常显示所选页面
+		if data, err := fetchStorms(ctx, provider, opt, stormCheckInterval); err == nil && data.nearby(opt.StormRadius) {
+			return drawStormPage(opt, data)
+		}
+	}
+	switch opt.Layout {
+	case "", LayoutMain:
+	case LayoutIndices:
+		return drawIndexPage(ctx, provider, opt)
+	case LayoutTides:
+		return drawTidePage(ctx, provider, opt)
+	default:
+		return nil, fmt.Errorf("未知页面布局:%s", opt.Layout)
+	}
 	//获取一言
 	oneSentence, err := api.GetOneSentenceLocal()
 	if err != nil {
@@ -35,24 +112,53 @@ func DerawImage(cityID, host, weatherKey, addiTitle, addiContent string, enableF
 		return nil, errors.New("一言接口获取失败,数据不符合要求：\n" + oneSentence.Hitokoto)
 	}
 
-	// 获取天气
-	var weatherInfo api.WeatherResp
-	if weatherKey == "" {
-		weatherInfo, err = api.GetWeather(cityID)
-	} else {
-		r1, _, err := api.GetCurrentWeather(cityID, host, weatherKey)
-		if err != nil {
-			return nil, err
+	// 并发获取天气、天文与历史天气,天文与历史天气获取失败时不影响其他内容
+	timeNow := time.Now()
+	var (
+		weatherInfo api.Weather
+		astronomy   api.Astronomy
+	)
+	tasks := []api.FetchTask{
+		{Name: "weather", Fetch: func() (func(), error) {
+			w, err := provider.GetWeather(ctx, opt.CityID)
+			return func() { weatherInfo = w }, err
+		}},
+		{Name: "astronomy", Fetch: func() (func(), error) {
+			a, err := getAstronomy(ctx, provider, opt.CityID, opt.AstronomyApi, timeNow)
+			return func() { astronomy = a }, err
+		}},
+	}
+	showHistory := false
+	for _, name := range opt.Widgets {
+		if name == WidgetHistory && opt.History != nil {
+			showHistory = true
 		}
-		r2, _, err := api.GetWeatherIndex(cityID, host, weatherKey)
-		if err != nil {
-			return nil, err
+	}
+	if showHistory {
+		if task, ok := historicalTask(ctx, provider, opt.History, opt.CityID, timeNow); ok {
+			tasks = append(tasks, task)
 		}
-		weatherInfo.Parse(&r1, &r2)
 	}
-	if err != nil {
+	errs := api.FetchAll(ctx, tasks...)
+	if err, ifSet := errs["weather"]; ifSet {
 		return nil, err
 	}
+	var history historyComparison
+	recordWeather(opt, weatherInfo)
+	// 换算为显示单位
+	weatherInfo = opt.Units.ApplyWeather(weatherInfo)
+	if showHistory {
+		history = compareHistory(opt.History, opt.CityID, weatherInfo.Now, timeNow)
+	}
+	astronomyErr := errs["astronomy"]
+	if astronomyErr == nil {
+		// 按日出日落切换昼夜图标
+		if astronomy.IsNight(timeNow) {
+			weatherInfo.Now.Icon = api.NightIcon(weatherInfo.Now.Icon)
+		} else {
+			weatherInfo.Now.Icon = api.DayIcon(weatherInfo.Now.Icon)
+		}
+	}
 	draw, err := Draw.NewCanvas(128, 296, Draw.GetRGBA(255, 255, 255, 255))
 	if err != nil {
 		return nil, err
@@ -66,34 +172,47 @@ func DerawImage(cityID, host, weatherKey, addiTitle, addiContent string, enableF
 	// 天气
 
 	// 天气情况
-	// 矩形背景宽度无字12,每一个字符加16,x原始38
-	tmpInt := stringsPkg.GetStrLen(weatherInfo.WeatherStatus.Text)
-	draw.DrawRoundedBox(38-(float64(tmpInt)*16/2), 5, 12+(float64(tmpInt)*16), 19, 3, Draw.GetRGBA(0, 0, 0, 255))
-	draw.DrawText(weatherInfo.WeatherStatus.Text, 16, Draw.GetRGBA(255, 255, 255, 255), 44-(tmpInt*16/2), 5)
+	drawConditionPill(draw, weatherInfo.Now.Text)
+	var tmpInt int
 
 	// 天气图标
-	draw.DrawWeatherIcon(weatherInfo.WeatherStatus.Icon, 48, Draw.GetRGBA(0, 0, 0, 255), 19, 32)
+	draw.DrawWeatherIcon(weatherInfo.Now.Icon, 48, Draw.GetRGBA(0, 0, 0, 255), 19, 32)
 
 	// 温度
-	temp, _ := strconv.ParseFloat(weatherInfo.WeatherStatus.Temp, 64)
-
-	// 默认为摄氏度显示
-	tempStr := fmt.Sprintf("%.0f°C", temp)
+	draw.DrawText(weatherInfo.Now.Temp.String(), 25, Draw.GetRGBA(0, 0, 0, 255), 19, 82)
 
-	if enableFahrenheit {
-		// 转换为华氏度
-		temp = temp*9/5 + 32
-		tempStr = fmt.Sprintf("%.0f°F", temp)
+	// 小组件,启用多个时每次刷新轮换显示
+	var widgets []func()
+	if opt.EnableAstronomy && astronomyErr == nil {
+		widgets = append(widgets, func() { drawAstronomy(draw, astronomy) })
 	}
-
-	draw.DrawText(tempStr, 25, Draw.GetRGBA(0, 0, 0, 255), 19, 82)
-
-	// 小组件
-	if weatherInfo.WeatherIndexs.Air.Category == "" {
+	for _, name := range opt.Widgets {
+		if f := weatherWidget(draw, name, weatherInfo.Now, history); f != nil {
+			widgets = append(widgets, f)
+		}
+	}
+	if weatherInfo.Missing[api.SectionIndices] == nil {
+		indices, tomorrow := selectIndices(&weatherInfo, opt, timeNow)
+		prefix := utils.Ifs(tomorrow, "明日", "")
+		for _, t := range opt.Indices {
+			if v, ifSet := indices[t.Type]; ifSet && v.Category != "" {
+				item := readout{prefix + v.Label(), v.Category}
+				widgets = append(widgets, func() { drawReadouts(draw, item) })
+			}
+		}
+	}
+	if len(widgets) > 0 {
+		widgets[utils.Abs(opt.Rotation)%len(widgets)]()
+	} else if weatherInfo.Missing[api.SectionIndices] != nil {
+		// 生活指数获取失败,标记缺失
+		draw.DrawText("空气质量", 12.5, Draw.GetRGBA(0, 0, 0, 255), 5, 116)
+		draw.DrawRoundedBox(62, 116, 18, 15, 3, Draw.GetRGBA(0, 0, 0, 255))
+		draw.DrawText("--", 12, Draw.GetRGBA(255, 255, 255, 255), 65, 116)
+	} else if weatherInfo.Indexs.Air.Category == "" {
 		// 风力等级
 		draw.DrawText("风力等级", 12.5, Draw.GetRGBA(0, 0, 0, 255), 10, 116)
 
-		windScale := weatherInfo.WeatherStatus.WindScale
+		windScale := weatherInfo.Now.WindScale.Format(0)
 		if len(windScale) == 1 {
 			windScale = "0" + windScale // 如果是一位数，前面加0
 		}
@@ -106,25 +225,25 @@ func DerawImage(cityID, host, weatherKey, addiTitle, addiContent string, enableF
 		// 空气质量
 		draw.DrawText("空气质量", 12.5, Draw.GetRGBA(0, 0, 0, 255), 5, 116)
 		// 矩形背景宽度无字6,每一个字符加12,x原始68
-		tmpInt = stringsPkg.GetStrLen(weatherInfo.WeatherIndexs.Air.Category)
+		tmpInt = stringsPkg.GetStrLen(weatherInfo.Indexs.Air.Category)
 		draw.DrawRoundedBox(68-(float64(tmpInt)*12/2), 116, 6+(float64(tmpInt)*12), 15, 3, Draw.GetRGBA(0, 0, 0, 255))
-		draw.DrawText(weatherInfo.WeatherIndexs.Air.Category, 12, Draw.GetRGBA(255, 255, 255, 255), 70-(tmpInt*12/2), 116)
+		draw.DrawText(weatherInfo.Indexs.Air.Category, 12, Draw.GetRGBA(255, 255, 255, 255), 70-(tmpInt*12/2), 116)
 	}
 
 	// 湿度
 	draw.DrawImageData(humidityPNG, 7, 132)
-	draw.DrawText(weatherInfo.WeatherStatus.Humidity+"%", 12, Draw.GetRGBA(0, 0, 0, 255), 25, 132)
+	draw.DrawText(weatherInfo.Now.Humidity.String(), 12, Draw.GetRGBA(0, 0, 0, 255), 25, 132)
 	// 湿度进度条
 	draw.DrawRoundedBox(5, 147, 80, 8, 3, Draw.GetRGBA(0, 0, 0, 255))
 	// 内填充
 	draw.DrawRoundedBox(6, 148, 78, 6, 3, Draw.GetRGBA(255, 255, 255, 255))
 	// 进度
-	tmpInt, _ = strconv.Atoi(weatherInfo.WeatherStatus.Humidity)
-	draw.DrawRoundedBox(7, 149, 76*(float64(tmpInt)/100), 4, 3, Draw.GetRGBA(0, 0, 0, 255))
+	if weatherInfo.Now.Humidity.Valid {
+		draw.DrawRoundedBox(7, 149, 76*(weatherInfo.Now.Humidity.Value/100), 4, 3, Draw.GetRGBA(0, 0, 0, 255))
+	}
 	draw.DrawBox(3, 158, 121, 1, Draw.GetRGBA(0, 0, 0, 255))
 
 	// 日期
-	timeNow := time.Now()
 	dayStr := fmt.Sprintf("%d月%d日", timeNow.Month(), timeNow.Day())
 	tmpInt = stringsPkg.GetStrLen(dayStr)
 	// 图像宽度128,每一个字符加16.5,x原始64
@@ -159,15 +278,86 @@ func DerawImage(cityID, host, weatherKey, addiTitle, addiContent string, enableF
 		draw.DrawText(s, 12, color, 10+(i*8+(8*i)), 182)
 	}
 
-	draw.DrawTextCenter(addiTitle, 12.5, Draw.GetRGBA(0, 0, 0, 255), 127, 202)
+	draw.DrawTextCenter(opt.AddiTitle, 12.5, Draw.GetRGBA(0, 0, 0, 255), 127, 202)
 	draw.DrawBox(3, 217, 121, 1, Draw.GetRGBA(0, 0, 0, 255))
 	top := 219
-	addiContents := strings.Split(addiContent, "\n")
+	addiContents := strings.Split(opt.AddiContent, "\n")
 	for _, s := range addiContents {
 		draw.DrawTextCenter(s, 12.5, Draw.GetRGBA(0, 0, 0, 255), 127, top)
 		top += 15
 	}
+	if weatherInfo.Stale {
+		drawStaleBadge(draw, weatherInfo.UpdateTime, timeNow)
+	}
+	if opt.ShowProvider {
+		draw.DrawTextRight(weatherInfo.Provider, 8, Draw.GetRGBA(0, 0, 0, 255), 126, 285)
+	}
 	// 预留代办内容
 	//draw.DrawText("无", 12.5, Draw.GetRGBA(0, 0, 0, 255), 57, 247)
 	return draw.SaveBytes()
 }
+
+// getAstronomy 获取天文数据
+//
+// 数据源支持且启用接口时优先使用接口,失败则退回离线计算
+func getAstronomy(ctx context.Context, provider api.WeatherProvider, cityID string, astronomyApi bool, date time.Time) (api.Astronomy, error) {
+	if p, ok := api.Capability[api.AstronomyProvider](provider); ok && astronomyApi {
+		ret, err := p.GetAstronomy(ctx, cityID, date)
+		if err == nil {
+			return ret, nil
+		}
+	}
+	return api.GetAstronomyLocal(cityID, date)
+}
+
+// drawAstronomy 画日出日落与月相小组件
+func drawAstronomy(draw *Draw.Canvas, astronomy api.Astronomy) {
+	// 月相图标
+	draw.DrawWeatherIcon(astronomy.MoonPhase.Icon, 14, Draw.GetRGBA(0, 0, 0, 255), 4, 116)
+	sunStr := "--:--/--:--"
+	if astronomy.SunAlwaysUp {
+		sunStr = "极昼"
+	} else if astronomy.SunAlwaysDown {
+		sunStr = "极夜"
+	} else if !astronomy.Sunrise.IsZero() {
+		sunStr = astronomy.Sunrise.Format("15:04") + "/" + astronomy.Sunset.Format("15:04")
+	}
+	// 矩形背景宽度为文字宽度加6
+	w := draw.MeasureText(sunStr, 12)
+	draw.DrawRoundedBox(20, 116, 6+w, 15, 3, Draw.GetRGBA(0, 0, 0, 255))
+	draw.DrawText(sunStr, 12, Draw.GetRGBA(255, 255, 255, 255), 23, 116)
+}
+
+// drawStaleBadge 画缓存数据标记
+func drawStaleBadge(draw *Draw.Canvas, updateTime string, now time.Time) {
+	str := "数据来自缓存"
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", updateTime, time.Local)
+	if err == nil {
+		if t.YearDay() == now.YearDay() && t.Year() == now.Year() {
+			str = "数据来自" + t.Format("15:04")
+		} else {
+			str = "数据来自" + t.Format("01-02 15:04")
+		}
+	}
+	// 矩形背景宽度为文字宽度加4
+	w := draw.MeasureText(str, 9)
+	draw.DrawRoundedBox(1, 283, 4+w, 12, 2, Draw.GetRGBA(0, 0, 0, 255))
+	draw.DrawText(str, 9, Draw.GetRGBA(255, 255, 255, 255), 3, 283)
+}
+
+// drawConditionPill 画天气情况
+//
+// 以左侧区域中线44为中心,文字过长时(例如英文描述)逐步缩小字号
+func drawConditionPill(draw *Draw.Canvas, text string) {
+	size := 16.0
+	w := draw.MeasureText(text, size)
+	for w > 76 && size > 9 {
+		size--
+		w = draw.MeasureText(text, size)
+	}
+	// 矩形背景宽度为文字宽度加12,高度随字号变化
+	h := size + 3
+	top := 5 + (19-h)/2
+	draw.DrawRoundedBox(44-w/2-6, top, w+12, h, 3, Draw.GetRGBA(0, 0, 0, 255))
+	draw.DrawText(text, size, Draw.GetRGBA(255, 255, 255, 255), int(44-w/2), int(top))
+}
@@ -0,0 +1,131 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"hw_weather_plugin/Draw"
+	"hw_weather_plugin/api"
+	"time"
+)
+
+const (
+	// LayoutMain 天气主页
+	LayoutMain = "main"
+	// LayoutIndices 生活指数建议页
+	LayoutIndices = "indices"
+)
+
+// 详情页未选择指数时默认显示穿衣、感冒与紫外线
+var defaultPageIndices = []string{"3", "9", "5"}
+
+// selectIndices 选择要显示的生活指数,晚间刷新时使用明日指数
+//
+// 数据源不支持多天指数时仍返回当天
+func selectIndices(weatherInfo *api.Weather, opt Options, now time.Time) (indices api.IndexSet, tomorrow bool) {
+	indices = weatherInfo.IndicesOn(0)
+	if opt.TomorrowAfter > 0 && now.Hour() >= opt.TomorrowAfter {
+		if v := weatherInfo.IndicesOn(1); v != nil {
+			return v, true
+		}
+	}
+	return indices, false
+}
+
+// drawIndexPage 画生活指数建议页
+//
+// 依次画出所选指数的名称、等级与完整建议,超出屏幕的内容省略
+func drawIndexPage(ctx context.Context, provider api.WeatherProvider, opt Options) ([]byte, error) {
+	timeNow := time.Now()
+	var weatherInfo api.Weather
+	errs := api.FetchAll(ctx, api.FetchTask{Name: "weather", Fetch: func() (func(), error) {
+		w, err := provider.GetWeather(ctx, opt.CityID)
+		return func() { weatherInfo = w }, err
+	}})
+	if err, ifSet := errs["weather"]; ifSet {
+		return nil, err
+	}
+	recordWeather(opt, weatherInfo)
+	if err := weatherInfo.Missing[api.SectionIndices]; err != nil {
+		return nil, fmt.Errorf("生活指数获取失败:%w", err)
+	}
+	draw, err := Draw.NewCanvas(128, 296, Draw.GetRGBA(255, 255, 255, 255))
+	if err != nil {
+		return nil, err
+	}
+	black := Draw.GetRGBA(0, 0, 0, 255)
+	white := Draw.GetRGBA(255, 255, 255, 255)
+
+	indices, tomorrow := selectIndices(&weatherInfo, opt, timeNow)
+	// 标题与日期
+	date := timeNow
+	title := "今日生活指数"
+	if tomorrow {
+		date = date.AddDate(0, 0, 1)
+		title = "明日生活指数"
+	}
+	w := draw.MeasureText(title, 14)
+	draw.DrawText(title, 14, black, int(64-w/2), 3)
+	dayStr := fmt.Sprintf("%d月%d日 %s", date.Month(), date.Day(), weatherInfo.Now.Text)
+	w = draw.MeasureText(dayStr, 10)
+	draw.DrawText(dayStr, 10, black, int(64-w/2), 21)
+	draw.DrawBox(3, 36, 121, 1, black)
+
+	types := make([]string, 0, len(opt.Indices))
+	for _, t := range opt.Indices {
+		types = append(types, t.Type)
+	}
+	if len(types) == 0 {
+		types = defaultPageIndices
+	}
+	// 底部留出缓存标记与数据源的位置
+	const bottom = 280
+	const lineHeight = 14
+	top := 41
+	for _, typ := range types {
+		v, ifSet := indices[typ]
+		if !ifSet || v.Category == "" {
+			continue
+		}
+		if top+17+lineHeight > bottom {
+			break
+		}
+		// 指数名称与等级
+		label := v.Label()
+		w = draw.MeasureText(label, 12)
+		draw.DrawRoundedBox(4, float64(top), w+8, 16, 3, black)
+		draw.DrawText(label, 12, white, 8, top)
+		draw.DrawText(v.Category, 12, black, int(4+w+8+5), top)
+		top += 18
+		// 建议内容
+		// 宽度留出余量,行首禁用的标点会挤入上一行
+		lines := draw.WrapText(v.Text, 11, 112)
+		for i, line := range lines {
+			if top+lineHeight > bottom {
+				break
+			}
+			// 最后一行放不下剩余内容时以省略号结尾
+			if i < len(lines)-1 && top+2*lineHeight > bottom {
+				line = ellipsis(draw, line, 11, 118)
+			}
+			draw.DrawText(line, 11, black, 6, top)
+			top += lineHeight
+		}
+		top += 6
+	}
+	if weatherInfo.Stale {
+		drawStaleBadge(draw, weatherInfo.UpdateTime, timeNow)
+	}
+	if opt.ShowProvider {
+		draw.DrawTextRight(weatherInfo.Provider, 8, black, 126, 285)
+	}
+	return draw.SaveBytes()
+}
+
+// ellipsis 在行尾加上省略号,超出宽度时去掉末尾的字
+func ellipsis(draw *Draw.Canvas, line string, size, width float64) string {
+	r := []rune(line)
+	for len(r) > 0 && draw.MeasureText(string(r)+"…", size) > width {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "…"
+}
@@ -0,0 +1,243 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"hw_weather_plugin/Draw"
+	"hw_weather_plugin/api"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LayoutStorms 台风路径页
+const LayoutStorms = "storms"
+
+// 台风地图的位置与大小,城市位于中心
+const (
+	stormMapLeft = 4
+	stormMapTop  = 40
+	stormMapSize = 120
+)
+
+// stormCheckInterval 按半径检查台风时重新获取台风列表的间隔
+//
+// 每次获取需要1+2N次请求(N为活跃台风数),台风移动较慢,无需每次刷新都获取
+const stormCheckInterval = time.Hour
+
+var (
+	stormLock sync.Mutex
+	// 最近一次获取的活跃台风,与城市无关,获取失败时同样记录时间,间隔内不再重试
+	stormChecked time.Time
+	stormTracks  []api.StormTrack
+	stormErr     error
+)
+
+// stormData 台风页所需的数据
+type stormData struct {
+	Place    string           // 城市名称
+	Lat, Lon float64          // 城市坐标
+	Tracks   []api.StormTrack // 活跃台风,按与城市的最近距离排列
+}
+
+// fetchStorms 获取活跃台风,并按当前位置与预报路径中离城市最近的距离排序
+//
+// maxAge: 上次获取的结果在该时间内时直接使用,为0时总是重新获取
+func fetchStorms(ctx context.Context, provider api.WeatherProvider, opt Options, maxAge time.Duration) (ret stormData, err error) {
+	loc, err := api.GetLocation(opt.CityID)
+	if err != nil {
+		return ret, err
+	}
+	lat, err1 := strconv.ParseFloat(loc.Latitude, 64)
+	lon, err2 := strconv.ParseFloat(loc.Longitude, 64)
+	if err1 != nil || err2 != nil {
+		return ret, fmt.Errorf("城市%s坐标无效", opt.CityID)
+	}
+	ret.Place, ret.Lat, ret.Lon = loc.Location, lat, lon
+	p, ok := api.Capability[api.StormProvider](provider)
+	if !ok {
+		return ret, fmt.Errorf("数据源%s不支持台风数据", provider.Name())
+	}
+	stormLock.Lock()
+	cached := maxAge > 0 && time.Since(stormChecked) < maxAge
+	tracks, err := stormTracks, stormErr
+	stormLock.Unlock()
+	if !cached {
+		errs := api.FetchAll(ctx, api.FetchTask{Name: "storms", Fetch: func() (func(), error) {
+			tracks, err := p.GetActiveStorms(ctx, api.BasinNP)
+			if err != nil && ctx.Err() != nil {
+				// 到达截止时间被取消,下次刷新再试
+				return nil, err
+			}
+			stormLock.Lock()
+			stormChecked, stormTracks, stormErr = time.Now(), tracks, err
+			stormLock.Unlock()
+			return nil, err
+		}})
+		if err, ifSet := errs["storms"]; ifSet {
+			return ret, err
+		}
+		stormLock.Lock()
+		tracks, err = stormTracks, stormErr
+		stormLock.Unlock()
+	}
+	if err != nil {
+		return ret, err
+	}
+	// 排序不影响缓存的列表
+	ret.Tracks = append([]api.StormTrack(nil), tracks...)
+	sort.SliceStable(ret.Tracks, func(i, j int) bool {
+		_, a := ret.Tracks[i].Nearest(lat, lon)
+		_, b := ret.Tracks[j].Nearest(lat, lon)
+		return a < b
+	})
+	return ret, nil
+}
+
+// nearby 是否有台风的当前位置或预报路径进入半径内
+//
+// radius: 半径,单位公里
+func (d stormData) nearby(radius float64) bool {
+	for _, t := range d.Tracks {
+		if _, km := t.Nearest(d.Lat, d.Lon); km <= radius {
+			return true
+		}
+	}
+	return false
+}
+
+// drawStormPage 画台风路径页
+//
+// 上方为以城市为中心的路径地图,实线为历史路径,虚线为预报路径;
+// 下方依次列出台风的等级、距离、实况与预报最强强度
+func drawStormPage(opt Options, data stormData) ([]byte, error) {
+	timeNow := time.Now()
+	draw, err := Draw.NewCanvas(128, 296, Draw.GetRGBA(255, 255, 255, 255))
+	if err != nil {
+		return nil, err
+	}
+	black := Draw.GetRGBA(0, 0, 0, 255)
+	white := Draw.GetRGBA(255, 255, 255, 255)
+
+	title := "台风路径"
+	w := draw.MeasureText(title, 14)
+	draw.DrawText(title, 14, black, int(64-w/2), 3)
+	sub := fmt.Sprintf("%s %d月%d日 %s", data.Place, timeNow.Month(), timeNow.Day(), timeNow.Format("15:04"))
+	w = draw.MeasureText(sub, 10)
+	draw.DrawText(sub, 10, black, int(64-w/2), 21)
+
+	drawStormMap(draw, opt, data)
+
+	if len(data.Tracks) == 0 {
+		str := "当前没有活跃台风"
+		w = draw.MeasureText(str, 12)
+		draw.DrawText(str, 12, black, int(64-w/2), 180)
+		return draw.SaveBytes()
+	}
+	const bottom = 290
+	top := stormMapTop + stormMapSize + 6
+	for _, t := range data.Tracks {
+		if top+16+3*13 > bottom {
+			break
+		}
+		// 名称与等级
+		name := t.Storm.Name
+		if name == "" {
+			name = t.Storm.ID
+		}
+		w = draw.MeasureText(name, 12)
+		draw.DrawRoundedBox(4, float64(top), w+8, 16, 3, black)
+		draw.DrawText(name, 12, white, 8, top)
+		draw.DrawText(api.StormTypeName(t.Now.Type), 12, black, int(4+w+8+5), top)
+		top += 18
+		// 距离与方位
+		km := t.Now.Distance(data.Lat, data.Lon)
+		dir := api.BearingName(api.Bearing(data.Lat, data.Lon, t.Now.Lat, t.Now.Lon))
+		draw.DrawText(fmt.Sprintf("距离%s 位于%s方", formatDistance(km, opt.Units), dir), 10, black, 6, top)
+		top += 13
+		// 实况强度
+		draw.DrawText(fmt.Sprintf("风速%s 气压%s", t.Now.WindSpeed.Convert(opt.Units.WindSpeed).String(),
+			t.Now.Pressure.Convert(opt.Units.Pressure).String()), 10, black, 6, top)
+		top += 13
+		// 预报最强强度,预报路径更靠近城市时加上最近的时间与距离
+		if len(t.Forecast) > 0 {
+			peak := t.Peak()
+			draw.DrawText(fmt.Sprintf("预报最强%s %s", api.StormTypeName(peak.Type),
+				peak.WindSpeed.Convert(opt.Units.WindSpeed).String()), 10, black, 6, top)
+			top += 13
+			if p, near := t.Nearest(data.Lat, data.Lon); near < km && top+13 <= bottom {
+				draw.DrawText(fmt.Sprintf("%s最近%s", p.Time.Local().Format("1月2日15时"), formatDistance(near, opt.Units)), 10, black, 6, top)
+				top += 13
+			}
+		}
+		top += 6
+	}
+	return draw.SaveBytes()
+}
+
+// formatDistance 按显示单位格式化距离,英制使用英里
+func formatDistance(km float64, units api.UnitSystem) string {
+	q := api.Quantity{Value: km, Unit: api.UnitKm, Valid: true}.Convert(units.Visibility)
+	return q.Format(0) + string(q.Unit)
+}
+
+// drawStormMap 画以城市为中心的台风路径地图
+//
+// 地图范围取自动显示的半径与各台风当前位置、预报路径中的最远距离,
+// 历史路径超出范围的部分裁掉
+func drawStormMap(draw *Draw.Canvas, opt Options, data stormData) {
+	black := Draw.GetRGBA(0, 0, 0, 255)
+	cosLat := math.Cos(data.Lat * math.Pi / 180)
+	// 以城市为原点的平面坐标,单位公里,向东向北为正
+	offset := func(lat, lon float64) (float64, float64) {
+		return (lon - data.Lon) * 111.32 * cosLat, (lat - data.Lat) * 110.57
+	}
+	extent := math.Max(opt.StormRadius, 300)
+	for _, t := range data.Tracks {
+		for _, p := range append([]api.StormPoint{t.Now}, t.Forecast...) {
+			x, y := offset(p.Lat, p.Lon)
+			extent = math.Max(extent, math.Max(math.Abs(x), math.Abs(y)))
+		}
+	}
+	extent *= 1.15
+	const half = stormMapSize / 2
+	cx, cy := float64(stormMapLeft+half), float64(stormMapTop+half)
+	project := func(p api.StormPoint) [2]float64 {
+		x, y := offset(p.Lat, p.Lon)
+		return [2]float64{cx + x/extent*half, cy - y/extent*half}
+	}
+
+	left, top := float64(stormMapLeft), float64(stormMapTop)
+	draw.DrawPolyline([][2]float64{
+		{left, top}, {left + stormMapSize, top}, {left + stormMapSize, top + stormMapSize},
+		{left, top + stormMapSize}, {left, top},
+	}, 1, nil, black)
+	draw.ClipRect(left, top, stormMapSize, stormMapSize)
+	if opt.StormRadius > 0 {
+		draw.DrawCircle(cx, cy, opt.StormRadius/extent*half, 0.5, black)
+	}
+	// 城市位置
+	draw.DrawBox(cx-3, cy-0.5, 7, 1, black)
+	draw.DrawBox(cx-0.5, cy-3, 1, 7, black)
+	for _, t := range data.Tracks {
+		track := make([][2]float64, 0, len(t.Track)+1)
+		for _, p := range t.Track {
+			track = append(track, project(p))
+		}
+		track = append(track, project(t.Now))
+		draw.DrawPolyline(track, 1, nil, black)
+		forecast := [][2]float64{project(t.Now)}
+		for _, p := range t.Forecast {
+			forecast = append(forecast, project(p))
+		}
+		draw.DrawPolyline(forecast, 1, []float64{3, 2}, black)
+		now := project(t.Now)
+		draw.FillCircle(now[0], now[1], 3, black)
+		draw.DrawText(t.Storm.Name, 9, black, int(now[0]+4), int(now[1]-5))
+	}
+	draw.ResetClip()
+	scale := "图幅" + formatDistance(extent*2, opt.Units)
+	draw.DrawTextRight(scale, 8, black, stormMapLeft+stormMapSize-2, stormMapTop+stormMapSize-11)
+}
@@ -0,0 +1,221 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hw_weather_plugin/Draw"
+	"hw_weather_plugin/api"
+	"math"
+	"sort"
+	"time"
+)
+
+// LayoutTides 潮汐页
+const LayoutTides = "tides"
+
+// 潮汐曲线的绘制范围
+const (
+	tideChartLeft   = 22
+	tideChartRight  = 124
+	tideChartTop    = 50
+	tideChartBottom = 148
+)
+
+// drawTidePage 画潮汐页
+//
+// 上方为当天的潮高曲线并标出满潮与干潮,下方为潮汐表,下一次潮汐反色显示
+func drawTidePage(ctx context.Context, provider api.WeatherProvider, opt Options) ([]byte, error) {
+	if opt.TidePOI == "" {
+		return nil, errors.New("潮汐站点不能为空")
+	}
+	p, ok := api.Capability[api.TideProvider](provider)
+	if !ok {
+		return nil, fmt.Errorf("数据源%s不支持潮汐数据", provider.Name())
+	}
+	timeNow := time.Now()
+	var table api.TideTable
+	errs := api.FetchAll(ctx, api.FetchTask{Name: "tide", Fetch: func() (func(), error) {
+		t, err := p.GetTide(ctx, opt.TidePOI, timeNow)
+		return func() { table = t }, err
+	}})
+	if err, ifSet := errs["tide"]; ifSet {
+		return nil, err
+	}
+	next, hasNext := table.Next(timeNow)
+	if !hasNext {
+		// 当天的潮汐已过,倒计时使用次日的第一次潮汐,获取失败时不显示倒计时
+		next, hasNext = nextDayTide(ctx, p, opt, timeNow)
+	}
+	draw, err := Draw.NewCanvas(128, 296, Draw.GetRGBA(255, 255, 255, 255))
+	if err != nil {
+		return nil, err
+	}
+	black := Draw.GetRGBA(0, 0, 0, 255)
+	white := Draw.GetRGBA(255, 255, 255, 255)
+
+	title := "今日潮汐"
+	w := draw.MeasureText(title, 14)
+	draw.DrawText(title, 14, black, int(64-w/2), 3)
+	dayStr := fmt.Sprintf("%d月%d日 满潮%d次 干潮%d次", timeNow.Month(), timeNow.Day(), countTides(table, true), countTides(table, false))
+	w = draw.MeasureText(dayStr, 10)
+	draw.DrawText(dayStr, 10, black, int(64-w/2), 21)
+	draw.DrawBox(3, 36, 121, 1, black)
+
+	drawTideCurve(draw, table, timeNow)
+
+	// 潮汐表
+	top := tideChartBottom + 24
+	for _, v := range table.Events {
+		if top+16 > 270 {
+			break
+		}
+		draw.DrawRoundedBox(4, float64(top), 32, 16, 3, black)
+		if hasNext && v.Time.Equal(next.Time) {
+			draw.DrawText(tideLabel(v.High), 12, white, 8, top)
+		} else {
+			// 其余潮汐为黑框白底
+			draw.DrawRoundedBox(5, float64(top+1), 30, 14, 2, white)
+			draw.DrawText(tideLabel(v.High), 12, black, 8, top)
+		}
+		draw.DrawText(v.Time.Format("15:04"), 12, black, 44, top)
+		draw.DrawTextRight(fmt.Sprintf("%.2fm", v.Height), 12, black, 124, top)
+		top += 19
+	}
+	if hasNext {
+		d := next.Time.Sub(timeNow)
+		str := fmt.Sprintf("距下次%s还有%d小时%d分", tideLabel(next.High), int(d.Hours()), int(d.Minutes())%60)
+		w = draw.MeasureText(str, 10)
+		draw.DrawText(str, 10, black, int(64-w/2), 276)
+	}
+	return draw.SaveBytes()
+}
+
+// nextDayTide 获取次日的第一次满潮或干潮,与当天的潮汐表共用截止时间
+func nextDayTide(ctx context.Context, p api.TideProvider, opt Options, now time.Time) (api.TideEvent, bool) {
+	var table api.TideTable
+	errs := api.FetchAll(ctx, api.FetchTask{Name: "tide", Fetch: func() (func(), error) {
+		t, err := p.GetTide(ctx, opt.TidePOI, now.AddDate(0, 0, 1))
+		return func() { table = t }, err
+	}})
+	if len(errs) > 0 {
+		return api.TideEvent{}, false
+	}
+	return table.Next(now)
+}
+
+// tideLabel 满潮或干潮的名称
+func tideLabel(high bool) string {
+	if high {
+		return "满潮"
+	}
+	return "干潮"
+}
+
+// countTides 统计满潮或干潮的次数
+func countTides(table api.TideTable, high bool) (ret int) {
+	for _, v := range table.Events {
+		if v.High == high {
+			ret++
+		}
+	}
+	return ret
+}
+
+// tideCurve 当天的潮高曲线
+//
+// 有逐小时数据时与满潮干潮合并后按时间连接;
+// 只有满潮干潮时,相邻两次之间按余弦曲线插值
+func tideCurve(table api.TideTable) []api.TideHeight {
+	var ret []api.TideHeight
+	if len(table.Hourly) >= 2 {
+		ret = append(ret, table.Hourly...)
+		for _, v := range table.Events {
+			ret = append(ret, api.TideHeight{Time: v.Time, Height: v.Height})
+		}
+		sort.SliceStable(ret, func(i, j int) bool {
+			return ret[i].Time.Before(ret[j].Time)
+		})
+		return ret
+	}
+	for i, v := range table.Events {
+		ret = append(ret, api.TideHeight{Time: v.Time, Height: v.Height})
+		if i == len(table.Events)-1 {
+			break
+		}
+		next := table.Events[i+1]
+		span := next.Time.Sub(v.Time)
+		for t := 15 * time.Minute; t < span; t += 15 * time.Minute {
+			ratio := float64(t) / float64(span)
+			h := (v.Height+next.Height)/2 + (v.Height-next.Height)/2*math.Cos(math.Pi*ratio)
+			ret = append(ret, api.TideHeight{Time: v.Time.Add(t), Height: h})
+		}
+	}
+	return ret
+}
+
+// drawTideCurve 画潮高曲线、时间刻度、当前时间与满潮干潮标记
+func drawTideCurve(draw *Draw.Canvas, table api.TideTable, now time.Time) {
+	black := Draw.GetRGBA(0, 0, 0, 255)
+	curve := tideCurve(table)
+	if len(curve) == 0 {
+		return
+	}
+	// 按站点时区的当天0点计算横坐标
+	loc := curve[0].Time.Location()
+	now = now.In(loc)
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	x := func(t time.Time) float64 {
+		return tideChartLeft + t.Sub(dayStart).Hours()/24*(tideChartRight-tideChartLeft)
+	}
+	low, high := table.Range()
+	// 上下留出标注文字的位置
+	pad := math.Max((high-low)*0.3, 0.1)
+	low, high = low-pad, high+pad
+	y := func(h float64) float64 {
+		return tideChartBottom - (h-low)/(high-low)*(tideChartBottom-tideChartTop)
+	}
+
+	// 坐标轴与刻度
+	draw.DrawBox(tideChartLeft, tideChartBottom, tideChartRight-tideChartLeft, 1, black)
+	for hour := 0; hour <= 24; hour += 6 {
+		px := tideChartLeft + float64(hour)/24*(tideChartRight-tideChartLeft)
+		draw.DrawBox(px, tideChartBottom, 1, 3, black)
+		label := fmt.Sprint(hour)
+		w := draw.MeasureText(label, 8)
+		draw.DrawText(label, 8, black, int(math.Min(px-w/2, tideChartRight-w)), tideChartBottom+4)
+	}
+	minH, maxH := table.Range()
+	draw.DrawTextRight(fmt.Sprintf("%.1f", maxH), 8, black, tideChartLeft-3, int(y(maxH)-5))
+	draw.DrawTextRight(fmt.Sprintf("%.1f", minH), 8, black, tideChartLeft-3, int(y(minH)-5))
+	draw.DrawTextRight("m", 8, black, tideChartLeft-3, tideChartTop-12)
+
+	draw.ClipRect(tideChartLeft, tideChartTop-12, tideChartRight-tideChartLeft, tideChartBottom-tideChartTop+12)
+	points := make([][2]float64, 0, len(curve))
+	for _, v := range curve {
+		points = append(points, [2]float64{x(v.Time), y(v.Height)})
+	}
+	draw.DrawPolyline(points, 1.5, nil, black)
+	// 当前时间
+	if px := x(now); px >= tideChartLeft && px <= tideChartRight {
+		draw.DrawPolyline([][2]float64{{px, tideChartTop - 12}, {px, tideChartBottom}}, 1, []float64{2, 2}, black)
+	}
+	draw.ResetClip()
+
+	// 满潮标注在上方,干潮标注在下方
+	for _, v := range table.Events {
+		px, py := x(v.Time), y(v.Height)
+		if px < tideChartLeft || px > tideChartRight {
+			continue
+		}
+		draw.FillCircle(px, py, 2.5, black)
+		label := v.Time.Format("15:04")
+		w := draw.MeasureText(label, 8)
+		lx := math.Max(tideChartLeft, math.Min(px-w/2, tideChartRight-w))
+		if v.High {
+			draw.DrawText(label, 8, black, int(lx), int(py-13))
+		} else {
+			draw.DrawText(label, 8, black, int(lx), int(py+3))
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"hw_weather_plugin/Draw"
+	"hw_weather_plugin/api"
+	"hw_weather_plugin/utils/utils"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 可放入小组件位置的天气组件
+const (
+	WidgetWind      = "wind"      // 风向罗盘与风速
+	WidgetPressure  = "pressure"  // 气压与能见度
+	WidgetFeelsLike = "feelslike" // 体感温度
+	WidgetDew       = "dew"       // 露点温度
+	WidgetHistory   = "history"   // 与昨日及去年同日比较
+)
+
+// Widgets 所有天气组件名称
+var Widgets = []string{WidgetWind, WidgetPressure, WidgetFeelsLike, WidgetDew, WidgetHistory}
+
+// ParseWidgets 解析以逗号分隔的天气组件列表
+func ParseWidgets(s string) ([]string, error) {
+	var ret []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name == "" {
+			continue
+		}
+		ok := false
+		for _, v := range Widgets {
+			ok = ok || v == name
+		}
+		if !ok {
+			return nil, fmt.Errorf("未知组件:%s", name)
+		}
+		ret = append(ret, name)
+	}
+	return ret, nil
+}
+
+// weatherWidget 获取天气组件的绘制函数
+func weatherWidget(draw *Draw.Canvas, name string, now api.Observation, history historyComparison) func() {
+	switch name {
+	case WidgetHistory:
+		return func() { drawHistory(draw, history) }
+	case WidgetWind:
+		return func() { drawWind(draw, now) }
+	case WidgetPressure:
+		return func() {
+			drawReadouts(draw,
+				readout{"气压", now.Pressure.String()},
+				readout{"能见", now.Vis.String()},
+			)
+		}
+	case WidgetFeelsLike:
+		return func() { drawReadouts(draw, readout{"体感温度", now.FeelsLike.String()}) }
+	case WidgetDew:
+		return func() { drawReadouts(draw, readout{"露点温度", now.Dew.String()}) }
+	}
+	return nil
+}
+
+// readout 小组件中的一项数据
+type readout struct {
+	Label string // 名称
+	Value string // 数值,画在黑底圆角矩形中
+}
+
+// drawReadouts 画一行名称与数值
+//
+// 总宽度超过80时逐步缩小字号
+func drawReadouts(draw *Draw.Canvas, items ...readout) {
+	size := 12.0
+	width := func() (w float64) {
+		for _, v := range items {
+			w += draw.MeasureText(v.Label, size+0.5) + 4 + draw.MeasureText(v.Value, size) + 6 + 3
+		}
+		return w - 3
+	}
+	for width() > 80 && size > 8 {
+		size--
+	}
+	top := 116 + (12-size)/2
+	left := 5.0
+	for _, v := range items {
+		draw.DrawText(v.Label, size+0.5, Draw.GetRGBA(0, 0, 0, 255), int(left), int(top))
+		left += draw.MeasureText(v.Label, size+0.5) + 4
+		w := draw.MeasureText(v.Value, size)
+		draw.DrawRoundedBox(left, 116, w+6, 15, 3, Draw.GetRGBA(0, 0, 0, 255))
+		draw.DrawText(v.Value, size, Draw.GetRGBA(255, 255, 255, 255), int(left+3), int(top))
+		left += w + 6 + 3
+	}
+}
+
+// drawWind 画风向罗盘与风速
+//
+// 箭头指向风的去向,即风向角度加180度
+func drawWind(draw *Draw.Canvas, now api.Observation) {
+	draw.DrawCircle(12, 123.5, 7, 1, Draw.GetRGBA(0, 0, 0, 255))
+	if now.Wind360.Valid {
+		draw.DrawArrow(12, 123.5, 10, now.Wind360.Value+180, Draw.GetRGBA(0, 0, 0, 255))
+	}
+	str := now.WindDir
+	if now.WindSpeed.Valid {
+		str += " " + now.WindSpeed.String()
+	}
+	if strings.TrimSpace(str) == "" {
+		str = "--"
+	}
+	size := 12.0
+	for draw.MeasureText(str, size) > 64 && size > 8 {
+		size--
+	}
+	draw.DrawText(str, size, Draw.GetRGBA(0, 0, 0, 255), 22, int(116+(12-size)/2))
+}
+
+// historyComparison 与历史天气的比较,使用显示单位
+type historyComparison struct {
+	Yesterday api.Quantity // 与昨日同一时间的温差
+	LastYear  api.Quantity // 去年同日同一时间的温度
+}
+
+var (
+	historicalLock sync.Mutex
+	// 历史天气接口获取失败的日期,键为城市ID,同一天不再重复请求
+	historicalFailed = make(map[string]string)
+)
+
+// historicalTask 本地缺少昨日同一时段的记录时,创建从历史天气接口获取并保存的任务
+//
+// 数据源不支持、本地已有记录或当天已获取失败时返回false
+func historicalTask(ctx context.Context, provider api.WeatherProvider, store *api.HistoryStore, cityID string, t time.Time) (api.FetchTask, bool) {
+	p, ok := api.Capability[api.HistoricalProvider](provider)
+	if !ok {
+		return api.FetchTask{}, false
+	}
+	yesterday := t.AddDate(0, 0, -1)
+	if day, ok, _ := store.Day(cityID, yesterday); ok {
+		if _, found := day.TempAt(t.Hour(), 2); found {
+			return api.FetchTask{}, false
+		}
+	}
+	date := yesterday.Format("2006-01-02")
+	historicalLock.Lock()
+	failed := historicalFailed[cityID] == date
+	historicalLock.Unlock()
+	if failed {
+		return api.FetchTask{}, false
+	}
+	return api.FetchTask{Name: "historical", Fetch: func() (func(), error) {
+		v, err := p.GetHistorical(ctx, cityID, yesterday)
+		if err != nil && ctx.Err() != nil {
+			// 到达截止时间被取消,下次刷新再试
+			return nil, err
+		}
+		if err != nil {
+			historicalLock.Lock()
+			historicalFailed[cityID] = date
+			historicalLock.Unlock()
+			return nil, err
+		}
+		// 直接在获取协程中保存,不依赖截止前执行的 apply
+		return nil, store.Put(cityID, v)
+	}}, true
+}
+
+// compareHistory 与本地历史天气比较
+func compareHistory(store *api.HistoryStore, cityID string, now api.Observation, t time.Time) (ret historyComparison) {
+	if !now.Temp.Valid {
+		return ret
+	}
+	if day, ok, _ := store.Day(cityID, t.AddDate(0, 0, -1)); ok {
+		if v, found := day.TempAt(t.Hour(), 2); found {
+			old := api.Quantity{Value: v, Unit: api.UnitCelsius, Valid: true}.Convert(now.Temp.Unit)
+			ret.Yesterday = api.Quantity{Value: now.Temp.Value - old.Value, Unit: api.UnitDegree, Valid: true}
+		}
+	}
+	if day, ok, _ := store.Day(cityID, t.AddDate(-1, 0, 0)); ok {
+		if v, found := day.TempAt(t.Hour(), 3); found {
+			ret.LastYear = api.Quantity{Value: v, Unit: api.UnitCelsius, Valid: true}.Convert(now.Temp.Unit)
+		}
+	}
+	return ret
+}
+
+// drawHistory 画与昨日及去年同日的比较
+func drawHistory(draw *Draw.Canvas, history historyComparison) {
+	delta := "--"
+	if history.Yesterday.Valid {
+		v := math.Round(history.Yesterday.Value)
+		if v == 0 {
+			v = 0 // 去掉负零
+		}
+		delta = utils.Ifs(v > 0, "+", utils.Ifs(v == 0, "±", "")) + strconv.FormatFloat(v, 'f', 0, 64) + "°"
+	}
+	if !history.LastYear.Valid {
+		drawReadouts(draw, readout{"较昨日", delta})
+		return
+	}
+	drawReadouts(draw,
+		readout{"昨日", delta},
+		readout{"去年", history.LastYear.Format(0) + "°"},
+	)
+}